@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"backend/utils"
+)
+
+// datasets served when there is no datasets.json
+var defaultDatasets = []utils.DatasetSource{
+	{Name: "default", Recipes: "recipes.json", Elements: "elements.json"},
+}
+
+// datasetSources reads the datasets to serve from DATASETS_CONFIG (default
+// datasets.json), a JSON list of {"name", "recipes", "elements"}. the first
+// one is served when a request has no dataset param
+func datasetSources() ([]utils.DatasetSource, error) {
+	filename := os.Getenv("DATASETS_CONFIG")
+	if filename == "" {
+		filename = "datasets.json"
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return defaultDatasets, nil
+		}
+	}
+
+	file, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading datasets config: %w", err)
+	}
+	var sources []utils.DatasetSource
+	if err := json.Unmarshal(file, &sources); err != nil {
+		return nil, fmt.Errorf("error unmarshaling datasets config: %w", err)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no datasets in %s", filename)
+	}
+	return sources, nil
+}
+
+// validate and load every dataset, any error stops the server
+func registerDatasets() {
+	sources, err := datasetSources()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	for _, source := range sources {
+		fmt.Printf("Loading dataset %s\n", source.Name)
+		report, err := utils.RegisterDataset(source)
+		reportOnStartup(report)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+}
+
+var reloadMu sync.Mutex
+
+// reloadDataset reads and validates the files of a dataset again and swaps in
+// the data it validated. on errors the current data is kept. searches already running finish
+// on the data they started with
+func reloadDataset(name string) (*utils.Dataset, utils.ValidationReport, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	return utils.ReloadDataset(name)
+}
+
+// poll the dataset files every RECIPES_POLL_INTERVAL (e.g. "10s"), off when unset
+func startRecipesWatcher() {
+	value := os.Getenv("RECIPES_POLL_INTERVAL")
+	if value == "" {
+		return
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		fmt.Printf("Invalid RECIPES_POLL_INTERVAL %q, not watching the datasets\n", value)
+		return
+	}
+	go watchRecipes(interval)
+}
+
+// reload a dataset whenever the modification time of one of its files
+// changes. a half written file fails validation, the next write changes the
+// time again
+func watchRecipes(interval time.Duration) {
+	last := make(map[string]time.Time)
+	for _, data := range utils.Datasets() {
+		last[data.Source.Name] = datasetModTime(data.Source)
+	}
+
+	for range time.Tick(interval) {
+		for _, data := range utils.Datasets() {
+			name := data.Source.Name
+			modified := datasetModTime(data.Source)
+			if modified.Equal(last[name]) {
+				continue
+			}
+			last[name] = modified
+
+			if _, _, err := reloadDataset(name); err != nil {
+				fmt.Println("Reloading", name, "failed:", err)
+				continue
+			}
+			fmt.Println("Reloaded dataset", name)
+		}
+	}
+}
+
+// the latest modification time of the dataset files
+func datasetModTime(source utils.DatasetSource) time.Time {
+	var latest time.Time
+	for _, filename := range []string{source.Recipes, source.Elements} {
+		if info, err := os.Stat(filename); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
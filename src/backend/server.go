@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"time"
 	"strconv"
+	"strings"
 	"os"
-	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"backend/utils"
@@ -21,10 +25,46 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// max time a single search may run, override with SEARCH_TIMEOUT (e.g. "10s", "2m")
+var searchTimeout = 30 * time.Second
+
+func loadSearchTimeout() {
+	value := os.Getenv("SEARCH_TIMEOUT")
+	if value == "" {
+		return
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		fmt.Printf("Invalid SEARCH_TIMEOUT %q, using %s\n", value, searchTimeout)
+		return
+	}
+	searchTimeout = timeout
+}
+
+// most trees one search may return, every tree is sent as its own full copy
+const maxRecipesLimit = 1000
+
+// turn a context error from a search into a status code and message
+func searchErrorStatus(err error) (int, string) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout, fmt.Sprintf("Search timed out after %s", searchTimeout)
+	}
+	if errors.Is(err, context.Canceled) {
+		return 499, "Search cancelled"
+	}
+	return http.StatusBadRequest, err.Error()
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:]))
+	}
+
 	// initialize recipes data
 	// utils.InitializeData() <-------- scrapping. just uncomment for production
-	utils.LoadRecipes("recipes.json")
+	registerDatasets()
+	loadSearchTimeout()
+	startRecipesWatcher()
 	router := gin.Default()
   
 	// cors 
@@ -45,72 +85,65 @@ func main() {
 			Errors: []string{},
 		}
 
-		// get query params
-		target := c.Query("target") // target recipe
-		algorithm_mode := c.Query("algo") // bfs dfs
-		search_mode := c.Query("shortest") // multi or shortest
-		max := c.Query("max") // max recipe tree if using multi mode
-
-		// validate query params
-		if algorithm_mode == "" || (search_mode == "" && max == "") || target == "" {
-			response.Errors = append(response.Errors, "Missing Query Parameters")
+		query, err := parseSearchQuery(c)
+		if err != nil {
+			response.Errors = append(response.Errors, err.Error())
 			c.JSON(http.StatusBadRequest, response)
 			return
 		}
 
-		if (search_mode != "true" && max == "") {
-			response.Errors = append(response.Errors, "Missing Query Parameters")
+		// json by default, text or markdown send the crafting checklist instead
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && !utils.ValidChecklistFormat(format) {
+			response.Errors = append(response.Errors, fmt.Sprintf("unknown format %s, expected json, %s or %s", format, utils.ChecklistText, utils.ChecklistMarkdown))
 			c.JSON(http.StatusBadRequest, response)
 			return
 		}
 
-		// parse query params
-		useBFS := false
-		findShortest := false
-		maxRecipes := 1
-		if algorithm_mode == "BFS" {
-			useBFS = true
-		}
-		if search_mode == "true" {
-			findShortest = true
-		}
-		if max != "" {
-			if val, err := strconv.Atoi(max); err == nil {
-				maxRecipes = val
-			} else {
-				response.Errors = append(response.Errors, "Max recipes paramaeter must be a number")
-				c.JSON(http.StatusBadRequest, response)
-				return
-			}
-		}
+		// search recipe, stops when the client disconnects or the timeout hits
+		ctx, cancel := context.WithTimeout(c.Request.Context(), searchTimeout)
+		defer cancel()
 
-		// search recipe
-		data, nodeCount, recipeFound, err := utils.Search(target,  findShortest, useBFS, maxRecipes)
+		result, err := runSearch(ctx, query, nil)
 		if err != nil {
-			response.Errors = append(response.Errors, err.Error())
-			c.JSON(http.StatusBadRequest, response)
+			status, message := searchErrorStatus(err)
+			response.Errors = append(response.Errors, message)
+			c.JSON(status, response)
 			return
 		}
 
 		// send search result
-		response.Data = utils.ConvertToJSONFormat(data)
-		response.NodeCount = nodeCount
-		response.RecipeFound = recipeFound
+		response = utils.NewJSONResponse(result)
 		response.Time = time.Since(start).Milliseconds()
 
+		if format != "json" {
+			checklist, err := utils.Checklist(result.Paths, format)
+			if err != nil {
+				response.Errors = append(response.Errors, err.Error())
+				c.JSON(http.StatusInternalServerError, response)
+				return
+			}
+			contentType := "text/plain; charset=utf-8"
+			if format == utils.ChecklistMarkdown {
+				contentType = "text/markdown; charset=utf-8"
+			}
+			c.Data(http.StatusOK, contentType, []byte(checklist))
+			return
+		}
+
 		c.JSON(http.StatusOK, response)
 	})
 
 
-	// live search pake websocket
+	// live search pake websocket, query params sama kayak /search.
+	// every utils.SearchEvent is streamed as it happens, then one "complete"
+	// message carrying the final tree (or one "error" message)
 	router.GET("/liveSearch", func(c *gin.Context) {
 		start := time.Now()
-		algorithm_mode := c.Query("algo") // bfs or dfs
-		search_mode := c.Query("mode") // multi or shortest
-		max_recipe := c.Query("max") // max recipe tree if using multi mode
 
-		if algorithm_mode == "" || search_mode == "" || max_recipe == "" {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Missing query parameters"})
+		query, err := parseSearchQuery(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 		
@@ -119,59 +152,497 @@ func main() {
 			fmt.Println("error upgrading http connection to a websocket.")
 			return
 		}
+		defer conn.Close()
 
-		// cara nge send data tinggal ganti yang WriteJSON ini jadi data lu,
-		// jadi for loop yg dibawah ini di inkorporasikan ke fungsi bfs/dfs nya buat
-		// ngesend node nya yang sekarang atau terserah gmn sih
+		ctx, cancel := context.WithTimeout(context.Background(), searchTimeout)
+		defer cancel()
 
-		defer conn.Close()
-		for i := 0; i < 10; i++ {
-			// Send progress data
-			err := conn.WriteJSON(map[string]interface{}{
-				"type": "progress",
-				"progress_counter":  i,
-				"data": fmt.Sprintf("data %d", i),
-				"timestamp": time.Now(),
-			})
+		// the client never sends anything, but reading is how a close or a
+		// dropped connection shows up while no events are being written
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					cancel()
+					return
+				}
+			}
+		}()
 
-			if err != nil {
-				fmt.Println("Write error:", err)
+		// the client is gone once a write fails, so stop the search too
+		var writeErr error
+		onEvent := func(event utils.SearchEvent) {
+			if writeErr != nil {
 				return
 			}
-
-			time.Sleep(time.Second / 2)
+			if writeErr = conn.WriteJSON(event); writeErr != nil {
+				fmt.Println("Write error:", writeErr)
+				cancel()
+			}
 		}
 
+		result, err := runSearch(ctx, query, onEvent)
+		if writeErr != nil {
+			return
+		}
+		if err != nil {
+			_, message := searchErrorStatus(err)
+			conn.WriteJSON(map[string]interface{}{
+				"type": "error",
+				"error": message,
+				"duration": time.Since(start).Seconds(),
+			})
+			return
+		}
 
 		// algo done so we send message telling its done to sever connection
-		conn.WriteJSON(map[string]interface{}{
-			"type": "complete",
-			"duration": time.Since(start).Seconds(),
+		response := utils.NewJSONResponse(result)
+		response.Time = time.Since(start).Milliseconds()
+		conn.WriteJSON(utils.LiveResult{
+			Type:         utils.EventComplete,
+			JSONResponse: response,
+			Duration:     time.Since(start).Seconds(),
 		})
 	})
 
 	router.GET("/elements", func(c *gin.Context) {
-		// Read file
-		jsonData, err := os.ReadFile("elements.json")
+		data, err := utils.GetDataset(c.Query("dataset"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to read JSON file",
-			})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// parse json
-		var jsonObj interface{}
-		if err := json.Unmarshal(jsonData, &jsonObj); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Invalid JSON format",
+		// send json
+		c.JSON(http.StatusOK, data.Elements)
+	})
+  
+	// every dataset the dataset param accepts, the first one is the default
+	router.GET("/datasets", func(c *gin.Context) {
+		list := []gin.H{}
+		for _, data := range utils.Datasets() {
+			list = append(list, gin.H{
+				"name": data.Source.Name,
+				"recipesFile": data.Source.Recipes,
+				"elementsFile": data.Source.Elements,
+				"elements": len(data.Elements),
+				"recipes": data.Recipes,
+				"loadedAt": data.LoadedAt,
 			})
+		}
+		c.JSON(http.StatusOK, list)
+	})
+  
+	// total number of distinct recipe trees under tier=strict, as a string since it can get huge.
+	// inventory and forbidden work like for /search
+	router.GET("/count", func(c *gin.Context) {
+		target := c.Query("target")
+		if target == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing query parameters"})
 			return
 		}
 
-		// send json
-		c.JSON(http.StatusOK, jsonObj)
+		data, err := utils.GetDataset(c.Query("dataset"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		options := utils.SearchOptions{
+			Inventory: parseList(c.Query("inventory")),
+			Forbidden: parseList(c.Query("forbidden")),
+			Tier: utils.TierPolicy(c.Query("tier")),
+		}
+		count, err := data.CountRecipeTrees(target, options)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"target": target,
+			"count": count.String(),
+		})
+	})
+  
+	// one shared crafting plan for several targets, e.g. targets=Human,Dragon,Computer
+	router.GET("/plan", func(c *gin.Context) {
+		targets := parseList(c.Query("targets"))
+		if len(targets) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing query parameters"})
+			return
+		}
+
+		data, err := utils.GetDataset(c.Query("dataset"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		options := utils.SearchOptions{
+			Inventory: parseList(c.Query("inventory")),
+			Forbidden: parseList(c.Query("forbidden")),
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), searchTimeout)
+		defer cancel()
+
+		plan, err := data.SearchPlan(ctx, targets, options)
+		if err != nil {
+			status, message := searchErrorStatus(err)
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+
+		c.JSON(http.StatusOK, plan)
+	})
+  
+	// what an element is used for, depth > 0 also follows the results downstream (-1 = all)
+	router.GET("/uses", func(c *gin.Context) {
+		element := c.Query("element")
+		if element == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing query parameters"})
+			return
+		}
+
+		data, err := utils.GetDataset(c.Query("dataset"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		depth := 0
+		if value := c.Query("depth"); value != "" {
+			val, err := strconv.Atoi(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Depth parameter must be a number"})
+				return
+			}
+			depth = val
+		}
+
+		uses, err := data.FindUses(element, depth)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, uses)
+	})
+  
+	// everything craftable from inventory (plus the base elements) and in how many rounds
+	router.GET("/reachable", func(c *gin.Context) {
+		data, err := utils.GetDataset(c.Query("dataset"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		reachability, err := data.FindReachable(parseList(c.Query("inventory")))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, reachability)
 	})
   
+	// every search algorithm the algo param accepts, with what it supports
+	router.GET("/algorithms", func(c *gin.Context) {
+		c.JSON(http.StatusOK, utils.Searchers())
+	})
+  
+	// diffs the smallest tree two algorithms, or two datasets, find for target
+	router.GET("/diff", func(c *gin.Context) {
+		target := c.Query("target")
+		if target == "" || c.Query("left") == "" || c.Query("right") == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing query parameters"})
+			return
+		}
+
+		options := utils.SearchOptions{
+			Inventory: parseList(c.Query("inventory")),
+			Forbidden: parseList(c.Query("forbidden")),
+			Required: parseList(c.Query("required")),
+		}
+		tier, err := utils.ParseTierPolicy(c.Query("tier"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		options.Tier = tier
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), searchTimeout)
+		defer cancel()
+
+		var trees [2]*utils.TreeNode
+		for i, side := range []string{"left", "right"} {
+			query := searchQuery{request: utils.SearchRequest{Target: target, FindShortest: true, MaxRecipes: 1, Options: options}}
+			if query.data, err = utils.GetDataset(c.Query(side + "Dataset")); err == nil {
+				query.searcher, err = utils.LookupSearcher(c.Query(side))
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": side + ": " + err.Error()})
+				return
+			}
+
+			result, err := runSearch(ctx, query, nil)
+			if err != nil {
+				status, message := searchErrorStatus(err)
+				c.JSON(status, gin.H{"error": side + ": " + message})
+				return
+			}
+			if trees[i] = utils.SmallestTree(result.Paths); trees[i] == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("%s: no tree found for %s", side, target)})
+				return
+			}
+		}
+
+		diff, err := utils.DiffTrees(trees[0], trees[1])
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, diff)
+	})
+
+	// diffs two trees in the /search data format
+	router.POST("/diff", func(c *gin.Context) {
+		var body struct {
+			Left  *utils.JSONRecipeNode `json:"left"`
+			Right *utils.JSONRecipeNode `json:"right"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid trees: " + err.Error()})
+			return
+		}
+
+		diff, err := utils.DiffJSONTrees(body.Left, body.Right)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, diff)
+	})
+
+	// checks a submitted route, either steps in crafting order or one tree
+	router.POST("/verify", func(c *gin.Context) {
+		var body struct {
+			Target    string                `json:"target"`
+			Inventory []string              `json:"inventory"`
+			Steps     []utils.RecipeStep    `json:"steps"`
+			Tree      *utils.JSONRecipeNode `json:"tree"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route: " + err.Error()})
+			return
+		}
+		if (body.Tree == nil) == (len(body.Steps) == 0) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Send either steps or tree"})
+			return
+		}
+
+		data, err := utils.GetDataset(c.Query("dataset"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		check, err := data.VerifyRoute(body.Target, body.Steps, body.Tree, body.Inventory)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, check)
+	})
+
+	// reload a dataset without restarting, searches already running are not affected.
+	// only served when ADMIN_TOKEN is set, it has to be sent as a Bearer token
+	if adminToken := os.Getenv("ADMIN_TOKEN"); adminToken != "" {
+		router.POST("/admin/reload", func(c *gin.Context) {
+			if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+adminToken)) != 1 {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+				return
+			}
+
+			data, report, err := reloadDataset(c.Query("dataset"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "report": report})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"dataset": data.Source.Name,
+				"loadedAt": data.LoadedAt,
+				"elements": len(data.Elements),
+				"recipes": data.Recipes,
+				"report": report,
+			})
+		})
+	}
+  
 	router.Run(":8081")
-}
\ No newline at end of file
+}
+
+type searchQuery struct {
+	data     *utils.Dataset
+	searcher utils.Searcher
+	request  utils.SearchRequest
+}
+
+// split a comma separated list param, e.g. inventory=Mud,Stone,Life
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parse costs=Fire:5,Water:2, recipeCosts=Fire+Water=Steam:3 (+ sent as %2B)
+// and tierCost=0.5, nil when none are given
+func parseCosts(c *gin.Context) (*utils.Costs, error) {
+	elements, recipes, tier := c.Query("costs"), c.Query("recipeCosts"), c.Query("tierCost")
+	if elements == "" && recipes == "" && tier == "" {
+		return nil, nil
+	}
+
+	costs := &utils.Costs{Elements: make(map[string]float64)}
+	for _, item := range parseList(elements) {
+		name, cost, err := parseCost(item)
+		if err != nil {
+			return nil, err
+		}
+		costs.Elements[name] = cost
+	}
+	for _, item := range parseList(recipes) {
+		recipe, cost, err := parseCost(item)
+		if err != nil {
+			return nil, err
+		}
+		ingredients, result, found := strings.Cut(recipe, "=")
+		ing1, ing2, found2 := strings.Cut(ingredients, "+")
+		if !found || !found2 {
+			return nil, fmt.Errorf("Recipe cost %s must look like Fire+Water=Steam:3", item)
+		}
+		costs.SetRecipe(utils.RecipeStep{
+			Ingredient1: strings.TrimSpace(ing1),
+			Ingredient2: strings.TrimSpace(ing2),
+			Result: strings.TrimSpace(result),
+		}, cost)
+	}
+	if tier != "" {
+		val, err := strconv.ParseFloat(tier, 64)
+		if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
+			return nil, fmt.Errorf("Tier cost parameter must be a finite number")
+		}
+		costs.Tier = val
+	}
+	return costs, nil
+}
+
+// split name:cost
+func parseCost(item string) (string, float64, error) {
+	index := strings.LastIndex(item, ":")
+	if index < 0 {
+		return "", 0, fmt.Errorf("Cost %s must look like name:cost", item)
+	}
+	cost, err := strconv.ParseFloat(item[index+1:], 64)
+	if err != nil || math.IsNaN(cost) || math.IsInf(cost, 0) {
+		return "", 0, fmt.Errorf("Cost %s must be a finite number", item)
+	}
+	return strings.TrimSpace(item[:index]), cost, nil
+}
+
+// parse & validate the query params shared by /search and /liveSearch
+func parseSearchQuery(c *gin.Context) (searchQuery, error) {
+	query := searchQuery{request: utils.SearchRequest{MaxRecipes: 1}}
+	options := &query.request.Options
+
+	// get query params
+	target := c.Query("target") // target recipe
+	algorithm_mode := c.Query("algo") // see /algorithms
+	search_mode := c.Query("shortest") // multi or shortest
+	max := c.Query("max") // max recipe tree if using multi mode
+	options.Inventory = parseList(c.Query("inventory")) // already discovered elements
+	options.Forbidden = parseList(c.Query("forbidden")) // elements the tree must not use
+	options.Required = parseList(c.Query("required")) // elements the tree must go through
+
+	tier, err := utils.ParseTierPolicy(c.Query("tier")) // strict, nonstrict or none
+	if err != nil {
+		return query, err
+	}
+	options.Tier = tier
+
+	if seed := c.Query("seed"); seed != "" { // shuffles exploration order, replayable
+		val, err := strconv.ParseInt(seed, 10, 64)
+		if err != nil {
+			return query, fmt.Errorf("Seed parameter must be a number")
+		}
+		options.Seed = &val
+	}
+
+	if workers := c.Query("workers"); workers != "" { // PARALLEL_BFS worker pool size
+		val, err := strconv.Atoi(workers)
+		if err != nil || val <= 0 {
+			return query, fmt.Errorf("Workers parameter must be a positive number")
+		}
+		options.Workers = val
+	}
+
+	costs, err := parseCosts(c) // CHEAPEST prices
+	if err != nil {
+		return query, err
+	}
+	options.Costs = costs
+
+	// validate query params
+	if algorithm_mode == "" || target == "" {
+		return query, fmt.Errorf("Missing Query Parameters")
+	}
+	query.data, err = utils.GetDataset(c.Query("dataset")) // default dataset when empty
+	if err != nil {
+		return query, err
+	}
+	searcher, err := utils.LookupSearcher(algorithm_mode)
+	if err != nil {
+		return query, err
+	}
+	query.searcher = searcher
+	query.request.Target = target
+
+	// single tree algorithms ignore shortest & max
+	if !searcher.Capabilities().MultipleTrees {
+		query.request.FindShortest = true
+		return query, nil
+	}
+
+	if (search_mode != "true" && max == "") {
+		return query, fmt.Errorf("Missing Query Parameters")
+	}
+
+	// parse query params
+	if search_mode == "true" {
+		query.request.FindShortest = true
+	}
+	if max != "" {
+		val, err := strconv.Atoi(max)
+		if err != nil {
+			return query, fmt.Errorf("Max recipes paramaeter must be a number")
+		}
+		if val < 1 {
+			return query, fmt.Errorf("Max recipes parameter must be at least 1")
+		}
+		if val > maxRecipesLimit {
+			return query, fmt.Errorf("Max recipes parameter must be at most %d", maxRecipesLimit)
+		}
+		query.request.MaxRecipes = val
+	}
+
+	return query, nil
+}
+
+func runSearch(ctx context.Context, query searchQuery, onEvent utils.EventFunc) (utils.SearchResult, error) {
+	return query.searcher.Search(ctx, query.data, query.request, onEvent)
+}
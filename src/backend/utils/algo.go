@@ -1,11 +1,13 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"sort"
-	"sync"
+	"strings"
 	// "time"
 )
 
@@ -16,15 +18,16 @@ type Recipe struct {
 }
 
 type RecipeStep struct {
-	Ingredient1 string
-	Ingredient2 string
-	Result      string
+	Ingredient1 string `json:"ingredient1"`
+	Ingredient2 string `json:"ingredient2"`
+	Result      string `json:"result"`
 }
 
 type TreeNode struct {
 	Element    string
 	Children   []*TreeNode
 	RecipeStep *RecipeStep
+	Cost       *float64 // subtree cost, only set by CheapestSearch
 }
 
 type TreeStats struct {
@@ -41,6 +44,7 @@ type RecipePath struct {
 type JSONRecipeNode struct {
 	Name     string              `json:"name"`
 	Recipes  [][2]*JSONRecipeNode   `json:"recipes,omitempty"`
+	Cost     *float64            `json:"cost,omitempty"` // subtree cost, CHEAPEST only
 }
 
 type JSONResponse struct {
@@ -49,418 +53,338 @@ type JSONResponse struct {
 	Time         int64       `json:"time"`          // milliseconds
 	NodeCount    int         `json:"nodeCount"`     // nodes visited
 	RecipeFound  int         `json:"recipeFound"`   // recipes found
+	Optimal      bool        `json:"optimal,omitempty"` // the result is the proven minimum, see SearchResult.Optimal
+	Discarded    PruneStats  `json:"discarded,omitempty"` // candidate recipes each tier policy discarded
+	Cost         *float64    `json:"cost,omitempty"`    // total tree cost, CHEAPEST only
+	Pareto       []ParetoPoint `json:"pareto,omitempty"` // depth versus size front, PARETO only
+	DAG          *CraftingDAG  `json:"dag,omitempty"`    // minimal crafting DAG, DAG only
+	Steps        [][]RecipeStep `json:"steps"`           // crafting order per tree, same order as data.recipes
+	TimedOut     bool           `json:"timedOut,omitempty"` // data is the best found before the timeout, see SearchResult.TimedOut
 }
 
-var baseElements = map[string]bool{
-	"Air":   true,
-	"Earth": true,
-	"Fire":  true,
-	"Water": true,
+// live search event types
+const (
+	EventVisit    = "visit"    // element dequeued (BFS) or popped (DFS)
+	EventRecipe   = "recipe"   // new recipe variant recorded for an intermediate element
+	EventTarget   = "target"   // new recipe variant recorded for the target
+	EventTree     = "tree"     // recipe tree built for one target variant
+	EventComplete = "complete" // search finished, see LiveResult
+)
+
+type SearchEvent struct {
+	Type    string          `json:"type"`
+	Element string          `json:"element,omitempty"`
+	Recipe  *RecipeStep     `json:"recipe,omitempty"`
+	Tree    *JSONRecipeNode `json:"tree,omitempty"`
+	Visits  int             `json:"progress_counter"` // elements visited so far
 }
 
-var (
-	graph map[string][][2]string
-	tiers map[string]int
-) 
+// LiveResult is the last live search message of a search that finished
+type LiveResult struct {
+	Type string `json:"type"` // EventComplete
+	JSONResponse
+	Duration float64 `json:"duration"` // seconds
+}
 
-func LoadRecipes(filename string) {
-	file, err := os.ReadFile(filename)
-	if err != nil {
-		fmt.Printf("Error reading recipes file: %v\n", err)
-		return
+// EventFunc receives search events. it is always called from the goroutine running the search
+type EventFunc func(SearchEvent)
+
+func (f EventFunc) emit(event SearchEvent) {
+	if f != nil {
+		f(event)
 	}
+}
 
-	var recipes []Recipe
-	if err := json.Unmarshal(file, &recipes); err != nil {
-		fmt.Printf("Error unmarshaling recipes: %v\n", err)
+func (f EventFunc) emitRecipe(recipe RecipeStep, target string, visits int) {
+	eventType := EventRecipe
+	if recipe.Result == target {
+		eventType = EventTarget
+	}
+	f.emit(SearchEvent{Type: eventType, Element: recipe.Result, Recipe: &recipe, Visits: visits})
+}
+
+func (f EventFunc) emitTree(path RecipePath, visits int) {
+	if f == nil {
 		return
 	}
+	f.emit(SearchEvent{Type: EventTree, Element: path.TreeRoot.Element, Tree: _convertToJSONFormat(path.TreeRoot), Visits: visits})
+}
 
-	graph = make(map[string][][2]string)
-	tiers = make(map[string]int)
+var baseElements = map[string]bool{
+	"Air":   true,
+	"Earth": true,
+	"Fire":  true,
+	"Water": true,
+}
 
-	for base := range baseElements {
-		tiers[base] = 0
-	}
+// SearchOptions holds the optional search constraints, the zero value
+// searches from the base elements only
+type SearchOptions struct {
+	Inventory []string // elements already discovered, leaves just like the base elements
+	Forbidden []string // elements that must not appear anywhere in a tree
+	Required  []string // elements every returned tree must contain
+	Tier      TierPolicy // recipes BFS and DFS accept, strict when empty
+	Seed      *int64     // shuffles the BFS and DFS exploration order, see explorationOrder
+	Workers   int        // ParallelBFS worker pool size, capped at and defaulting to one per CPU
+	Costs     *Costs     // prices for the CHEAPEST search, see Costs
+}
 
-	for _, r := range recipes {
-		if len(r.Recipe) == 2 {
-			graph[r.Result] = append(graph[r.Result], [2]string{r.Recipe[0], r.Recipe[1]})
-		}
-		if _, exists := tiers[r.Result]; !exists {
-			tiers[r.Result] = r.Tier
-		}
-	}
+// SearchStats describes the exploration of one BFS or DFS run
+type SearchStats struct {
+	Visits    int
+	Discarded PruneStats
 }
 
-func findRecipes(ing1, ing2 string, graph map[string][][2]string) []string {
-	var results []string
+// SearchResult is what Search and the other search modes return
+type SearchResult struct {
+	Paths       []RecipePath
+	NodeCount   int
+	RecipeFound int
+	Optimal     bool       // proven minimum: NodeCount, Cost when set, every Pareto tree at its depth or the DAG actions
+	Discarded   PruneStats // nil for modes that do not prune by tier
+	Cost        *float64   // total tree cost, only set by SearchCheapest
+	Pareto      []ParetoPoint // one per path, only set by SearchPareto
+	DAG         *CraftingDAG  // only set by SearchDAG
+	TimedOut    bool          // ctx ran out and Paths is the best found so far, only SearchDAG does this
+}
 
-	for result, recipes := range graph {
-		for _, recipe := range recipes {
-			if (recipe[0] == ing1 && recipe[1] == ing2) ||
-				(recipe[0] == ing2 && recipe[1] == ing1) {
-				results = append(results, result)
-			}
+// trees are tracked per subset of the required elements, so keep it small
+const maxRequired = 6
+
+// requirements maps each required element to its own bit, a tree's mask is
+// the set of required elements it contains
+type requirements struct {
+	bits map[string]uint
+	full uint
+}
+
+func (opts SearchOptions) requirements() requirements {
+	req := requirements{bits: make(map[string]uint)}
+	for _, element := range opts.Required {
+		if _, exists := req.bits[element]; !exists {
+			req.bits[element] = 1 << len(req.bits)
 		}
 	}
-
-	return results
+	req.full = 1<<len(req.bits) - 1
+	return req
 }
 
-func BFS(target string, graph map[string][][2]string, tiers map[string]int, maxRecipes int) ([]RecipePath, int) {
-	craftable := make(map[string]bool)
-	visited := make(map[string]bool)
-	recipeVariants := make(map[string][]RecipeStep)
-	visitCount := 0
+func (req requirements) self(element string) uint {
+	return req.bits[element]
+}
 
-	// Initialize base elements
-	for base := range baseElements {
-		craftable[base] = true
-		visited[base] = true
+// variantLimit is how many recipe variants per element a search keeps.
+// a tree through the required elements may hide behind any variant, so keep them all
+func (opts SearchOptions) variantLimit(maxRecipes int) int {
+	if len(opts.Required) > 0 {
+		return math.MaxInt
 	}
+	return maxRecipes
+}
 
-	queue := make([]string, 0, len(baseElements))
+// leaves returns the elements a search starts from and never expands
+func (opts SearchOptions) leaves() map[string]bool {
+	forbidden := opts.forbidden()
+	leaves := make(map[string]bool, len(baseElements)+len(opts.Inventory))
 	for base := range baseElements {
-		queue = append(queue, base)
+		if !forbidden[base] {
+			leaves[base] = true
+		}
 	}
-
-	for len(queue) > 0 && len(recipeVariants[target]) < maxRecipes {
-		current := queue[0]
-		queue = queue[1:]
-		visitCount++
-
-		for ingredient := range craftable {
-			possibleResults := findRecipes(current, ingredient, graph)
-			for _, result := range possibleResults {
-				resultTier := tiers[result]
-				currentTier := tiers[current]
-				ingredientTier := tiers[ingredient]
-
-				if resultTier > currentTier && resultTier > ingredientTier {
-					newRecipe := RecipeStep{
-						Ingredient1: current,
-						Ingredient2: ingredient,
-						Result:      result,
-					}
-
-					if len(recipeVariants[result]) < maxRecipes {
-						isDuplicate := false
-						for _, existing := range recipeVariants[result] {
-							if (existing.Ingredient1 == current && existing.Ingredient2 == ingredient) ||
-								(existing.Ingredient1 == ingredient && existing.Ingredient2 == current) {
-								isDuplicate = true
-								break
-							}
-						}
-						if !isDuplicate {
-							recipeVariants[result] = append(recipeVariants[result], newRecipe)
-						}
-					}
-
-					if !craftable[result] {
-						craftable[result] = true
-						visited[result] = true
-						queue = append(queue, result)
-					}
-				}
-			}
+	for _, element := range opts.Inventory {
+		if !forbidden[element] {
+			leaves[element] = true
 		}
 	}
+	return leaves
+}
 
-	if !craftable[target] {
-		fmt.Printf("Cannot craft %s from base elements\n", target)
-		return nil, visitCount
+func (opts SearchOptions) forbidden() map[string]bool {
+	forbidden := make(map[string]bool, len(opts.Forbidden))
+	for _, element := range opts.Forbidden {
+		forbidden[element] = true
 	}
+	return forbidden
+}
 
-	var allPaths []RecipePath
-	processedCount := 0
-
-	if maxRecipes > 1 {
-		resultChan := make(chan RecipePath, maxRecipes)
-		var wg sync.WaitGroup
-		maxWorkers := 3
-		sem := make(chan struct{}, maxWorkers)
+// uncraftable explains why a search found no tree for target
+func (opts SearchOptions) uncraftable(target string) error {
+	if opts.forbidden()[target] {
+		return fmt.Errorf("%s is a forbidden element", target)
+	}
+	if len(opts.Forbidden) > 0 && len(opts.Required) > 0 {
+		return fmt.Errorf("%s cannot be crafted through %s without %s", target, strings.Join(opts.Required, ", "), strings.Join(opts.Forbidden, ", "))
+	}
+	if len(opts.Forbidden) > 0 {
+		return fmt.Errorf("%s cannot be crafted without %s", target, strings.Join(opts.Forbidden, ", "))
+	}
+	if len(opts.Required) > 0 {
+		return fmt.Errorf("%s cannot be crafted through %s", target, strings.Join(opts.Required, ", "))
+	}
+	return fmt.Errorf("no recipes found for %s", target)
+}
 
-		recipesToProcess := 0
-		for range recipeVariants[target] {
-			if processedCount >= maxRecipes {
-				break
-			}
-			processedCount++
-			recipesToProcess++
+func (opts SearchOptions) validate(tiers map[string]int) error {
+	for _, element := range opts.Inventory {
+		if _, exists := tiers[element]; !exists {
+			return fmt.Errorf("unknown inventory element %s", element)
 		}
-
-		processedCount = 0
-
-		for _, recipeVariant := range recipeVariants[target] {
-			if processedCount >= maxRecipes {
-				break
-			}
-			processedCount++
-
-			wg.Add(1)
-			sem <- struct{}{}
-			go func(recipe RecipeStep) {
-				defer wg.Done()
-				defer func() { <-sem }()
-
-				recipeMap := buildIterativeRecipeMap(recipe, recipeVariants)
-				craftingPath := make([]RecipeStep, 0, len(recipeMap))
-				for _, step := range recipeMap {
-					craftingPath = append(craftingPath, step)
-				}
-
-				treeRoot := buildCraftingTreeFromMap(target, recipeMap, make(map[string]bool))
-				resultChan <- RecipePath{craftingPath, treeRoot}
-			}(recipeVariant)
+	}
+	for _, element := range opts.Forbidden {
+		if _, exists := tiers[element]; !exists {
+			return fmt.Errorf("unknown forbidden element %s", element)
 		}
+	}
 
-		go func() {
-			wg.Wait()
-			close(resultChan)
-		}()
-
-		collectedCount := 0
-		for path := range resultChan {
-			allPaths = append(allPaths, path)
-			collectedCount++
-			if collectedCount >= recipesToProcess {
-				break
-			}
+	forbidden := opts.forbidden()
+	for _, element := range opts.Required {
+		if _, exists := tiers[element]; !exists {
+			return fmt.Errorf("unknown required element %s", element)
 		}
-	} else {
-		for _, recipeVariant := range recipeVariants[target] {
-			if processedCount >= maxRecipes {
-				break
-			}
-			processedCount++
-
-			recipeMap := buildIterativeRecipeMap(recipeVariant, recipeVariants)
-			craftingPath := make([]RecipeStep, 0, len(recipeMap))
-			for _, step := range recipeMap {
-				craftingPath = append(craftingPath, step)
-			}
-
-			treeRoot := buildCraftingTreeFromMap(target, recipeMap, make(map[string]bool))
-			allPaths = append(allPaths, RecipePath{craftingPath, treeRoot})
-			recipeMap = nil
+		if forbidden[element] {
+			return fmt.Errorf("%s is both required and forbidden", element)
 		}
 	}
+	if _, err := ParseTierPolicy(string(opts.Tier)); err != nil {
+		return err
+	}
+	if len(opts.requirements().bits) > maxRequired {
+		return fmt.Errorf("at most %d required elements are supported", maxRequired)
+	}
+	return nil
+}
 
-	sort.Slice(allPaths, func(i, j int) bool {
-		return len(allPaths[i].Steps) < len(allPaths[j].Steps)
-	})
+// unordered ingredient pair -> elements it crafts
+type PairIndex map[[2]string][]string
 
-	if len(allPaths) > maxRecipes {
-		allPaths = allPaths[:maxRecipes]
+func readRecipes(filename string) ([]Recipe, error) {
+	file, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading recipes file: %w", err)
 	}
 
-	return allPaths, visitCount
-}
-
-func DFS(target string, graph map[string][][2]string, tiers map[string]int, maxRecipes int) ([]RecipePath, int) {
-	if maxRecipes <= 0 {
-		maxRecipes = 1
+	var recipes []Recipe
+	if err := json.Unmarshal(file, &recipes); err != nil {
+		return nil, fmt.Errorf("error unmarshaling recipes: %w", err)
 	}
+	return recipes, nil
+}
 
-	craftable := make(map[string]bool)
-	recipeVariants := make(map[string][]RecipeStep)
-	visitCount := 0
-	visited := make(map[string]bool)
-	stack := []string{}
+// buildGraph turns the recipe list into result -> ingredient pairs and element -> tier
+func buildGraph(recipes []Recipe) (map[string][][2]string, map[string]int) {
+	graph := make(map[string][][2]string)
+	tiers := make(map[string]int)
 
 	for base := range baseElements {
-		craftable[base] = true
-		visited[base] = true
-		stack = append(stack, base)
+		tiers[base] = 0
 	}
 
-	for len(stack) > 0 && len(recipeVariants[target]) < maxRecipes {
-		lastIdx := len(stack) - 1
-		current := stack[lastIdx]
-		stack = stack[:lastIdx]
-		visitCount++
-
-		for ingredient := range craftable {
-			possibleResults := findRecipes(current, ingredient, graph)
-
-			for _, result := range possibleResults {
-				resultTier := tiers[result]
-				currentTier := tiers[current]
-				ingredientTier := tiers[ingredient]
-
-				if resultTier > currentTier && resultTier > ingredientTier {
-					newRecipe := RecipeStep{
-						Ingredient1: current,
-						Ingredient2: ingredient,
-						Result:      result,
-					}
-
-					if len(recipeVariants[result]) < maxRecipes {
-						isDuplicate := false
-						for _, existingRecipe := range recipeVariants[result] {
-							if (existingRecipe.Ingredient1 == current && existingRecipe.Ingredient2 == ingredient) ||
-								(existingRecipe.Ingredient1 == ingredient && existingRecipe.Ingredient2 == current) {
-								isDuplicate = true
-								break
-							}
-						}
-
-						if !isDuplicate {
-							recipeVariants[result] = append(recipeVariants[result], newRecipe)
-						}
-					}
-
-					if !craftable[result] && !visited[result] {
-						craftable[result] = true
-						visited[result] = true
-						stack = append(stack, result)
-					}
-				}
-			}
+	for _, r := range recipes {
+		if len(r.Recipe) == 2 {
+			graph[r.Result] = append(graph[r.Result], [2]string{r.Recipe[0], r.Recipe[1]})
+		}
+		if _, exists := tiers[r.Result]; !exists {
+			tiers[r.Result] = r.Tier
 		}
 	}
+	return graph, tiers
+}
 
-	if !craftable[target] {
-		fmt.Printf("Cannot craft %s from base elements\n", target)
-		return nil, visitCount
+func pairKey(ing1, ing2 string) [2]string {
+	if ing1 > ing2 {
+		ing1, ing2 = ing2, ing1
 	}
+	return [2]string{ing1, ing2}
+}
 
-	var allPaths []RecipePath
-	processedCount := 0
-
-	if maxRecipes > 1 {
-		resultChan := make(chan RecipePath, maxRecipes)
-		var wg sync.WaitGroup
-		maxWorkers := 3
-		sem := make(chan struct{}, maxWorkers)
-
-		for _, recipeVariant := range recipeVariants[target] {
-			if processedCount >= maxRecipes {
-				break
-			}
-			processedCount++
-
-			wg.Add(1)
-			sem <- struct{}{}
-			go func(recipe RecipeStep) {
-				defer wg.Done()
-				defer func() { <-sem }()
-
-				recipeMap := buildIterativeRecipeMap(recipe, recipeVariants)
-				craftingPath := make([]RecipeStep, 0, len(recipeMap))
-				for _, step := range recipeMap {
-					craftingPath = append(craftingPath, step)
-				}
-
-				treeRoot := buildCraftingTreeFromMap(target, recipeMap, make(map[string]bool))
-				resultChan <- RecipePath{craftingPath, treeRoot}
-				recipeMap = nil
-			}(recipeVariant)
+func (idx PairIndex) add(ing1, ing2, result string) {
+	key := pairKey(ing1, ing2)
+	for _, existing := range idx[key] {
+		if existing == result {
+			return
 		}
+	}
+	idx[key] = append(idx[key], result)
+}
 
-		go func() {
-			wg.Wait()
-			close(resultChan)
-		}()
-
-		for path := range resultChan {
-			allPaths = append(allPaths, path)
-		}
-	} else {
-		for _, recipeVariant := range recipeVariants[target] {
-			if processedCount >= maxRecipes {
-				break
-			}
-			processedCount++
+// Lookup returns every element crafted by combining ing1 and ing2, in either order
+func (idx PairIndex) Lookup(ing1, ing2 string) []string {
+	return idx[pairKey(ing1, ing2)]
+}
 
-			recipeMap := buildIterativeRecipeMap(recipeVariant, recipeVariants)
-			craftingPath := make([]RecipeStep, 0, len(recipeMap))
-			for _, step := range recipeMap {
-				craftingPath = append(craftingPath, step)
-			}
+// FindRecipes looks up what ing1 + ing2 crafts in the dataset
+func (d *Dataset) FindRecipes(ing1, ing2 string) []string {
+	return d.Pairs.Lookup(ing1, ing2)
+}
 
-			treeRoot := buildCraftingTreeFromMap(target, recipeMap, make(map[string]bool))
-			allPaths = append(allPaths, RecipePath{craftingPath, treeRoot})
-			recipeMap = nil
-		}
-	}
+func findRecipes(ing1, ing2 string, index PairIndex) []string {
+	return index.Lookup(ing1, ing2)
+}
 
-	sort.Slice(allPaths, func(i, j int) bool {
-		return len(allPaths[i].Steps) < len(allPaths[j].Steps)
-	})
+func BFS(ctx context.Context, target string, index PairIndex, tiers map[string]int, maxRecipes int, opts SearchOptions, onEvent EventFunc) ([]RecipePath, SearchStats, error) {
+	x := newExploration(target, tiers, maxRecipes, opts, onEvent)
+	order := opts.explorationOrder()
 
-	if len(allPaths) > maxRecipes {
-		allPaths = allPaths[:maxRecipes]
+	// Initialize base elements & inventory
+	queue := order.start(x.leaves)
+	for _, base := range queue {
+		x.discover(base)
 	}
 
-	return allPaths, visitCount
-}
-
-func buildIterativeRecipeMap(recipe RecipeStep, recipeVariants map[string][]RecipeStep) map[string]RecipeStep {
-	stack := []RecipeStep{recipe}
-	recipeMap := make(map[string]RecipeStep)
+	for len(queue) > 0 && !x.done() {
+		if err := ctx.Err(); err != nil {
+			return nil, x.stats(), err
+		}
 
-	for len(stack) > 0 {
-		current := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
+		current := queue[0]
+		queue = queue[1:]
+		x.visit(current)
 
-		if _, exists := recipeMap[current.Result]; !exists {
-			recipeMap[current.Result] = current
-			if !baseElements[current.Ingredient1] {
-				stack = append(stack, recipeVariants[current.Ingredient1][0])
-			}
-			if !baseElements[current.Ingredient2] {
-				stack = append(stack, recipeVariants[current.Ingredient2][0])
+		for _, ingredient := range order.arrange(x.craftableList) {
+			for _, result := range order.arrange(findRecipes(current, ingredient, index)) {
+				if x.record(current, ingredient, result) {
+					queue = append(queue, result)
+				}
 			}
 		}
 	}
-	return recipeMap
+
+	return x.trees(ctx, opts, maxRecipes, treeWorkers)
 }
 
-func buildCraftingTreeFromMap(element string, recipeMap map[string]RecipeStep, path map[string]bool) *TreeNode {
-	if path[element] {
-		return &TreeNode{
-			Element:  element,
-			Children: nil,
-		}
+func DFS(ctx context.Context, target string, index PairIndex, tiers map[string]int, maxRecipes int, opts SearchOptions, onEvent EventFunc) ([]RecipePath, SearchStats, error) {
+	if maxRecipes <= 0 {
+		maxRecipes = 1
 	}
 
-	if baseElements[element] {
-		return &TreeNode{
-			Element:  element,
-			Children: nil,
-		}
+	x := newExploration(target, tiers, maxRecipes, opts, onEvent)
+	order := opts.explorationOrder()
+
+	stack := order.start(x.leaves)
+	for _, base := range stack {
+		x.discover(base)
 	}
 
-	recipe, exists := recipeMap[element]
-	if !exists {
-		return &TreeNode{
-			Element:  element,
-			Children: nil,
+	for len(stack) > 0 && !x.done() {
+		if err := ctx.Err(); err != nil {
+			return nil, x.stats(), err
 		}
-	}
 
-	newPath := make(map[string]bool, len(path)+1)
-	for k, v := range path {
-		newPath[k] = v
-	}
-	newPath[element] = true
+		lastIdx := len(stack) - 1
+		current := stack[lastIdx]
+		stack = stack[:lastIdx]
+		x.visit(current)
 
-	node := &TreeNode{
-		Element:    element,
-		RecipeStep: &recipe,
-		Children:   make([]*TreeNode, 0),
+		for _, ingredient := range order.arrange(x.craftableList) {
+			for _, result := range order.arrange(findRecipes(current, ingredient, index)) {
+				if x.record(current, ingredient, result) {
+					stack = append(stack, result)
+				}
+			}
+		}
 	}
 
-	ing1 := recipe.Ingredient1
-	ing2 := recipe.Ingredient2
-
-	node.Children = append(node.Children, buildCraftingTreeFromMap(ing1, recipeMap, newPath))
-	node.Children = append(node.Children, buildCraftingTreeFromMap(ing2, recipeMap, newPath))
-
-	return node
+	return x.trees(ctx, opts, maxRecipes, treeWorkers)
 }
 
 func calculateTreeStats(root *TreeNode) TreeStats {
@@ -486,38 +410,49 @@ func calculateTreeStats(root *TreeNode) TreeStats {
 	return stats
 }
 
-func Search(target string, findShortest bool, useBFS bool, maxRecipes int) ([]RecipePath, int, int, error) {
-	var recipePaths []RecipePath
+// Search runs traverse over the dataset and keeps the smallest tree or up to
+// maxRecipes trees. it stops early and returns ctx.Err() once ctx is
+// cancelled or its deadline passes
+func (d *Dataset) Search(ctx context.Context, traverse Traversal, req SearchRequest, onEvent EventFunc) (SearchResult, error) {
+	target, maxRecipes, opts := req.Target, req.MaxRecipes, req.Options
+	if err := opts.validate(d.Tiers); err != nil {
+		return SearchResult{}, err
+	}
 
-	if useBFS {
-		fmt.Println("Finding shortest recipe using BFS...")
-		recipePaths, _ = BFS(target, graph, tiers, maxRecipes)
-	} else {
-		fmt.Println("Finding shortest recipe using DFS...")
-		recipePaths, _ = DFS(target, graph, tiers, maxRecipes)
+	// a base or inventory element needs no crafting, it is a tree of its own
+	if required := opts.requirements(); opts.leaves()[target] && required.self(target) == required.full {
+		root := &TreeNode{Element: target}
+		path := RecipePath{treeSteps(root), root}
+		onEvent.emitTree(path, 0)
+		return SearchResult{Paths: []RecipePath{path}, NodeCount: 1, RecipeFound: 1}, nil
+	}
+
+	recipePaths, stats, err := traverse(ctx, target, d.Pairs, d.Tiers, maxRecipes, opts, onEvent)
+	if err != nil {
+		return SearchResult{}, err
 	}
 	
 	fmt.Printf("number of recipes: %d\n", len((recipePaths)))
 	if len(recipePaths) > 0 {		
-		if findShortest {
-			sort.Slice(recipePaths, func(i, j int) bool {
+		if req.FindShortest {
+			sort.SliceStable(recipePaths, func(i, j int) bool {
 				return len(recipePaths[i].Steps) < len(recipePaths[j].Steps)
 			})
 
 			path := recipePaths[0]
-			stats := calculateTreeStats(path.TreeRoot)
-			return []RecipePath{path}, stats.NodeCount, len(recipePaths), nil
+			treeStats := calculateTreeStats(path.TreeRoot)
+			return SearchResult{Paths: []RecipePath{path}, NodeCount: treeStats.NodeCount, RecipeFound: len(recipePaths), Discarded: stats.Discarded}, nil
 		} else {
 			var nodeCount int
 
 			for _, path := range recipePaths {
-				stats := calculateTreeStats(path.TreeRoot)
-				nodeCount += stats.NodeCount
+				treeStats := calculateTreeStats(path.TreeRoot)
+				nodeCount += treeStats.NodeCount
 			}
-			return recipePaths, nodeCount, len(recipePaths), nil
+			return SearchResult{Paths: recipePaths, NodeCount: nodeCount, RecipeFound: len(recipePaths), Discarded: stats.Discarded}, nil
 		}
 	} else {
-		return nil, 0, 0, fmt.Errorf("no recipes found for %s", target)
+		return SearchResult{}, opts.uncraftable(target)
 	}
 }
 
@@ -528,6 +463,7 @@ func _convertToJSONFormat(node *TreeNode) *JSONRecipeNode {
 
 	jsonNode := &JSONRecipeNode{
 		Name: node.Element,
+		Cost: node.Cost,
 	}
 
 	// Base elements or nodes without children don't have recipes
@@ -554,6 +490,10 @@ func ConvertToJSONFormat(recipes []RecipePath) *JSONRecipeNode {
 	jsonNode := &JSONRecipeNode{
 		Name: recipes[0].TreeRoot.Element,
 	}
+	// with several trees under one root there is no single root cost
+	if len(recipes) == 1 {
+		jsonNode.Cost = recipes[0].TreeRoot.Cost
+	}
 
 	for _, recipe := range recipes {
 		jsonNode.Recipes = append(jsonNode.Recipes, _convertToJSONFormat(recipe.TreeRoot).Recipes...)
@@ -562,6 +502,28 @@ func ConvertToJSONFormat(recipes []RecipePath) *JSONRecipeNode {
 	return jsonNode
 }
 
+// NewJSONResponse carries everything a search found into a response, Time is
+// left to the caller. a search that timed out with a result says so in Errors
+func NewJSONResponse(result SearchResult) JSONResponse {
+	response := JSONResponse{
+		Data:        ConvertToJSONFormat(result.Paths),
+		Errors:      []string{},
+		NodeCount:   result.NodeCount,
+		RecipeFound: result.RecipeFound,
+		Optimal:     result.Optimal,
+		Discarded:   result.Discarded,
+		Cost:        result.Cost,
+		Pareto:      result.Pareto,
+		DAG:         result.DAG,
+		Steps:       PathSteps(result.Paths),
+		TimedOut:    result.TimedOut,
+	}
+	if result.TimedOut {
+		response.Errors = append(response.Errors, "Search timed out, returning the best result found so far")
+	}
+	return response
+}
+
 
 // Convert the tree to JSON structure
 func WriteTreeToJSONFile(recipes []RecipePath, filename string) error {
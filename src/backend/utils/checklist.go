@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checklist formats for Checklist
+const (
+	ChecklistText     = "text"
+	ChecklistMarkdown = "markdown"
+)
+
+// ValidChecklistFormat reports whether Checklist accepts format
+func ValidChecklistFormat(format string) bool {
+	return format == ChecklistText || format == ChecklistMarkdown
+}
+
+// PathSteps returns the crafting order of every tree, in the order of paths
+// and so of the recipes under ConvertToJSONFormat's root
+func PathSteps(paths []RecipePath) [][]RecipeStep {
+	steps := make([][]RecipeStep, 0, len(paths))
+	for _, path := range paths {
+		if path.Steps == nil {
+			steps = append(steps, []RecipeStep{})
+			continue
+		}
+		steps = append(steps, path.Steps)
+	}
+	return steps
+}
+
+// Checklist renders every tree as a numbered list of crafts to follow in game,
+// "1. Fire + Water → Steam", with a heading per tree. format is ChecklistText
+// or ChecklistMarkdown, which also adds a checkbox to every step
+func Checklist(paths []RecipePath, format string) (string, error) {
+	if !ValidChecklistFormat(format) {
+		return "", fmt.Errorf("unknown checklist format %s, expected %s or %s", format, ChecklistText, ChecklistMarkdown)
+	}
+
+	var b strings.Builder
+	for i, path := range paths {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		title := path.TreeRoot.Element
+		if len(paths) > 1 {
+			title = fmt.Sprintf("%s (recipe %d of %d)", title, i+1, len(paths))
+		}
+		if format == ChecklistMarkdown {
+			fmt.Fprintf(&b, "## %s\n\n", title)
+		} else {
+			fmt.Fprintf(&b, "%s\n", title)
+		}
+
+		if len(path.Steps) == 0 {
+			fmt.Fprintf(&b, "Nothing to craft, %s is already available.\n", path.TreeRoot.Element)
+			continue
+		}
+		box := ""
+		if format == ChecklistMarkdown {
+			box = "[ ] "
+		}
+		for n, step := range path.Steps {
+			fmt.Fprintf(&b, "%d. %s%s + %s → %s\n", n+1, box, step.Ingredient1, step.Ingredient2, step.Result)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Costs prices crafting trees for the CHEAPEST search. every node of a tree
+// costs its element's cost plus Tier per tier of the element, and every
+// crafted node also costs its recipe's cost. the zero value costs 1 per node,
+// so the cheapest tree is the smallest one
+type Costs struct {
+	Elements map[string]float64     // cost of a node holding the element, 1 when not listed
+	Recipes  map[RecipeStep]float64 // extra cost of crafting with the recipe, 0 when not listed
+	Tier     float64                // added per tier of a node's element
+}
+
+// recipeCostKey ignores ingredient order
+func recipeCostKey(recipe RecipeStep) RecipeStep {
+	pair := pairKey(recipe.Ingredient1, recipe.Ingredient2)
+	return RecipeStep{Ingredient1: pair[0], Ingredient2: pair[1], Result: recipe.Result}
+}
+
+// SetRecipe prices ing1 + ing2 = result, in either ingredient order
+func (c *Costs) SetRecipe(recipe RecipeStep, cost float64) {
+	if c.Recipes == nil {
+		c.Recipes = make(map[RecipeStep]float64)
+	}
+	c.Recipes[recipeCostKey(recipe)] = cost
+}
+
+// invalidCost rejects negative costs, NaN and infinities. they break the
+// ordering the minimum tree search relies on
+func invalidCost(cost float64) bool {
+	return cost < 0 || math.IsNaN(cost) || math.IsInf(cost, 0)
+}
+
+func (c *Costs) validate(graph map[string][][2]string, tiers map[string]int) error {
+	if invalidCost(c.Tier) {
+		return fmt.Errorf("tier cost must be a finite number, not negative")
+	}
+	for element, cost := range c.Elements {
+		if _, exists := tiers[element]; !exists {
+			return fmt.Errorf("unknown cost element %s", element)
+		}
+		if invalidCost(cost) {
+			return fmt.Errorf("cost of %s must be a finite number, not negative", element)
+		}
+	}
+	for recipe, cost := range c.Recipes {
+		if !hasRecipe(graph, recipe) {
+			return fmt.Errorf("unknown recipe %s + %s = %s", recipe.Ingredient1, recipe.Ingredient2, recipe.Result)
+		}
+		if invalidCost(cost) {
+			return fmt.Errorf("cost of %s + %s = %s must be a finite number, not negative", recipe.Ingredient1, recipe.Ingredient2, recipe.Result)
+		}
+	}
+	return nil
+}
+
+func hasRecipe(graph map[string][][2]string, recipe RecipeStep) bool {
+	key := pairKey(recipe.Ingredient1, recipe.Ingredient2)
+	for _, pair := range graph[recipe.Result] {
+		if pairKey(pair[0], pair[1]) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// treeWeights prices tree nodes for solveMinimumTrees
+type treeWeights struct {
+	costs *Costs // nil counts nodes
+	tiers map[string]int
+}
+
+var nodeCount = treeWeights{}
+
+func (w treeWeights) node(element string) float64 {
+	if w.costs == nil {
+		return 1
+	}
+	cost, listed := w.costs.Elements[element]
+	if !listed {
+		cost = 1
+	}
+	return cost + w.costs.Tier*float64(w.tiers[element])
+}
+
+func (w treeWeights) craft(recipe RecipeStep) float64 {
+	if w.costs == nil {
+		return 0
+	}
+	return w.costs.Recipes[recipeCostKey(recipe)]
+}
+
+// annotateCosts sets Cost on every node of root to the cost of its subtree
+func annotateCosts(root *TreeNode, weights treeWeights) float64 {
+	cost := weights.node(root.Element)
+	if root.RecipeStep != nil {
+		cost += weights.craft(*root.RecipeStep)
+	}
+	for _, child := range root.Children {
+		cost += annotateCosts(child, weights)
+	}
+	root.Cost = &cost
+	return cost
+}
+
+// CheapestSearch returns the minimum total cost crafting tree for target under
+// costs, with every node annotated with the cost of its subtree
+func CheapestSearch(ctx context.Context, target string, graph map[string][][2]string, tiers map[string]int, costs Costs, opts SearchOptions, onEvent EventFunc) (RecipePath, float64, error) {
+	weights := treeWeights{costs: &costs, tiers: tiers}
+	trees, err := solveMinimumTrees(ctx, graph, opts, weights, target, onEvent)
+	if err != nil {
+		return RecipePath{}, 0, err
+	}
+
+	root, cost, ok := trees.tree(target)
+	if !ok {
+		return RecipePath{}, 0, opts.uncraftable(target)
+	}
+	annotateCosts(root, weights)
+
+	path := RecipePath{treeSteps(root), root}
+	onEvent.emitTree(path, len(trees.settled))
+	return path, cost, nil
+}
+
+// SearchCheapest is Dataset.Search for the minimum cost tree under opts.Costs, it always returns one tree
+func (d *Dataset) SearchCheapest(ctx context.Context, target string, opts SearchOptions, onEvent EventFunc) (SearchResult, error) {
+	if err := opts.validate(d.Tiers); err != nil {
+		return SearchResult{}, err
+	}
+	var costs Costs
+	if opts.Costs != nil {
+		costs = *opts.Costs
+	}
+	if err := costs.validate(d.Graph, d.Tiers); err != nil {
+		return SearchResult{}, err
+	}
+
+	fmt.Println("Finding cheapest recipe...")
+	path, cost, err := CheapestSearch(ctx, target, d.Graph, d.Tiers, costs, opts, onEvent)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	treeStats := calculateTreeStats(path.TreeRoot)
+	return SearchResult{Paths: []RecipePath{path}, NodeCount: treeStats.NodeCount, RecipeFound: 1, Optimal: true, Cost: &cost}, nil
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// CountTrees counts the distinct crafting trees for element under the strict
+// tier policy, with ingredient order ignored like CanonicalTree does.
+// strict pruning makes every recipe go strictly up in tier, so there are no cycles
+// and the count is a sum of products over an acyclic graph. the looser policies
+// keep trees depending on discovery order, see acyclicVariants, so they are not counted.
+// opts.Tier is ignored, the inventory counts as leaves and forbidden elements have no trees
+func CountTrees(element string, graph map[string][][2]string, tiers map[string]int, opts SearchOptions) *big.Int {
+	return countTrees(element, graph, tiers, opts.leaves(), opts.forbidden(), make(map[string]*big.Int))
+}
+
+func countTrees(element string, graph map[string][][2]string, tiers map[string]int, leaves, forbidden map[string]bool, memo map[string]*big.Int) *big.Int {
+	if forbidden[element] {
+		return big.NewInt(0)
+	}
+	if leaves[element] {
+		return big.NewInt(1)
+	}
+	if cached, ok := memo[element]; ok {
+		return cached
+	}
+
+	total := new(big.Int)
+	seen := make(map[[2]string]bool)
+	for _, recipe := range graph[element] {
+		key := pairKey(recipe[0], recipe[1])
+		if seen[key] || !tierAllows(recipe, element, tiers) {
+			continue
+		}
+		seen[key] = true
+
+		left := countTrees(recipe[0], graph, tiers, leaves, forbidden, memo)
+		if recipe[0] == recipe[1] {
+			// n trees on both sides give n(n+1)/2 unordered pairs
+			pairs := new(big.Int).Add(left, big.NewInt(1))
+			pairs.Mul(pairs, left)
+			pairs.Rsh(pairs, 1)
+			total.Add(total, pairs)
+			continue
+		}
+		right := countTrees(recipe[1], graph, tiers, leaves, forbidden, memo)
+		total.Add(total, new(big.Int).Mul(left, right))
+	}
+
+	memo[element] = total
+	return total
+}
+
+// CountRecipeTrees counts the distinct recipe trees for target in the dataset,
+// from the inventory and without the forbidden elements. only the strict tier
+// policy can be counted
+func (d *Dataset) CountRecipeTrees(target string, opts SearchOptions) (*big.Int, error) {
+	if _, exists := d.Tiers[target]; !exists {
+		return nil, fmt.Errorf("unknown element %s", target)
+	}
+	if err := opts.validate(d.Tiers); err != nil {
+		return nil, err
+	}
+	if len(opts.Required) > 0 {
+		return nil, fmt.Errorf("required elements are not supported for counting")
+	}
+	if policy, _ := ParseTierPolicy(string(opts.Tier)); policy != TierStrict {
+		return nil, fmt.Errorf("recipe trees can only be counted for tier=%s", TierStrict)
+	}
+	return CountTrees(target, d.Graph, d.Tiers, opts), nil
+}
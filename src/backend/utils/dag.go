@@ -0,0 +1,464 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DAGNode is one element of a crafting DAG
+type DAGNode struct {
+	Element string `json:"element"`
+	Owned   bool   `json:"owned"` // a leaf, never crafted
+	Step    int    `json:"step"`  // position in Steps counting from 1, 0 for owned elements
+}
+
+// DAGEdge means From is an ingredient of To
+type DAGEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// CraftingDAG crafts target with every element crafted once and reused
+// wherever it is needed, so it has one action per crafted element
+type CraftingDAG struct {
+	Target      string       `json:"target"`
+	Nodes       []DAGNode    `json:"nodes"`       // owned elements first, then crafting order
+	Edges       []DAGEdge    `json:"edges"`       // ordered like the nodes they point to
+	Steps       []RecipeStep `json:"steps"`       // crafting order, ingredients always come first
+	Actions     int          `json:"actions"`     // unique combinations, len(Steps)
+	TreeActions int          `json:"treeActions"` // combinations of the tree the search started from, crafting every node
+	Saved       int          `json:"saved"`       // TreeActions - Actions, what reuse gains over that tree, never negative
+	Optimal     bool         `json:"optimal"`     // the search finished, Actions is the proven minimum
+	TimedOut    bool         `json:"timedOut"`    // ctx ran out before the search finished
+}
+
+// the DAG search gives up proving optimality after this many recipe choices
+const maxDAGExpansions = 2000000
+
+// dagSearch looks for the fewest crafted elements that craft target. it works
+// top down: every pending element still needs a recipe, choosing one makes its
+// ingredients pending unless they are owned or already crafted. a partial DAG
+// costs at least its crafted plus pending elements, which prunes every branch
+// that cannot beat the best DAG so far
+type dagSearch struct {
+	ctx        context.Context
+	onEvent    EventFunc
+	recipes    map[string][]RecipeStep // element -> recipes to try, most promising first
+	leaves     map[string]bool
+	required   []string
+	chosen     map[string]RecipeStep
+	pending    map[string]bool
+	best       map[string]RecipeStep
+	bestCost   int
+	expansions int
+	err        error
+}
+
+func newDAGSearch(ctx context.Context, graph map[string][][2]string, opts SearchOptions, sizes map[string]int, onEvent EventFunc) *dagSearch {
+	leaves := opts.leaves()
+	forbidden := opts.forbidden()
+
+	// recipes that add fewer and smaller new ingredients first
+	recipes := make(map[string][]RecipeStep)
+	for result, pairs := range graph {
+		if forbidden[result] || leaves[result] {
+			continue
+		}
+		for _, pair := range pairs {
+			if forbidden[pair[0]] || forbidden[pair[1]] || pair[0] == result || pair[1] == result {
+				continue
+			}
+			if _, ok := sizes[pair[0]]; !ok {
+				continue
+			}
+			if _, ok := sizes[pair[1]]; !ok {
+				continue
+			}
+			recipes[result] = append(recipes[result], RecipeStep{Ingredient1: pair[0], Ingredient2: pair[1], Result: result})
+		}
+		sort.SliceStable(recipes[result], func(i, j int) bool {
+			a, b := recipes[result][i], recipes[result][j]
+			return sizes[a.Ingredient1]+sizes[a.Ingredient2] < sizes[b.Ingredient1]+sizes[b.Ingredient2]
+		})
+	}
+
+	return &dagSearch{
+		ctx:      ctx,
+		onEvent:  onEvent,
+		recipes:  recipes,
+		leaves:   leaves,
+		required: opts.Required,
+		chosen:   make(map[string]RecipeStep),
+		pending:  make(map[string]bool),
+		bestCost: math.MaxInt,
+	}
+}
+
+// offer keeps chosen as the best DAG if it is smaller and has every required element
+func (s *dagSearch) offer(chosen map[string]RecipeStep, target string) {
+	if len(chosen) >= s.bestCost {
+		return
+	}
+	used := map[string]bool{target: true}
+	for _, recipe := range chosen {
+		used[recipe.Ingredient1] = true
+		used[recipe.Ingredient2] = true
+	}
+	for _, element := range s.required {
+		if !used[element] {
+			return
+		}
+	}
+
+	s.best = make(map[string]RecipeStep, len(chosen))
+	for element, recipe := range chosen {
+		s.best[element] = recipe
+	}
+	s.bestCost = len(chosen)
+}
+
+// seed offers the part of chosen that crafting target goes through as the
+// first DAG to beat, unless it loops. it reports whether it was taken
+func (s *dagSearch) seed(chosen map[string]RecipeStep, target string) bool {
+	chosen = reachableDAG(chosen, target)
+	if !acyclic(chosen) {
+		return false
+	}
+	s.offer(chosen, target)
+	return s.best != nil
+}
+
+// dependsOn reports whether crafting element needs target somewhere below it
+func (s *dagSearch) dependsOn(element, target string) bool {
+	return dependsOn(s.chosen, element, target)
+}
+
+func dependsOn(chosen map[string]RecipeStep, element, target string) bool {
+	seen := make(map[string]bool)
+	stack := []string{element}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == target {
+			return true
+		}
+		recipe, crafted := chosen[current]
+		if !crafted || seen[current] {
+			continue
+		}
+		seen[current] = true
+		stack = append(stack, recipe.Ingredient1, recipe.Ingredient2)
+	}
+	return false
+}
+
+// reachableDAG keeps the recipes of chosen that crafting target goes through
+func reachableDAG(chosen map[string]RecipeStep, target string) map[string]RecipeStep {
+	reached := make(map[string]RecipeStep)
+	stack := []string{target}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		recipe, crafted := chosen[current]
+		if _, seen := reached[current]; !crafted || seen {
+			continue
+		}
+		reached[current] = recipe
+		stack = append(stack, recipe.Ingredient1, recipe.Ingredient2)
+	}
+	return reached
+}
+
+// acyclic reports whether no element of chosen needs itself to be crafted
+func acyclic(chosen map[string]RecipeStep) bool {
+	for element, recipe := range chosen {
+		if dependsOn(chosen, recipe.Ingredient1, element) || dependsOn(chosen, recipe.Ingredient2, element) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *dagSearch) run(target string) {
+	if s.err != nil {
+		return
+	}
+	if len(s.chosen)+len(s.pending) >= s.bestCost {
+		return
+	}
+	if len(s.pending) == 0 {
+		s.offer(s.chosen, target)
+		return
+	}
+
+	// the pending element with the fewest recipes fails first
+	element := ""
+	for candidate := range s.pending {
+		if element == "" || len(s.recipes[candidate]) < len(s.recipes[element]) ||
+			(len(s.recipes[candidate]) == len(s.recipes[element]) && candidate < element) {
+			element = candidate
+		}
+	}
+
+	s.expansions++
+	if s.expansions%1024 == 0 {
+		s.err = s.ctx.Err()
+		s.onEvent.emit(SearchEvent{Type: EventVisit, Element: element, Visits: s.expansions})
+	}
+	if s.expansions > maxDAGExpansions {
+		s.err = errDAGBudget
+	}
+	if s.err != nil {
+		return
+	}
+
+	delete(s.pending, element)
+	for _, recipe := range s.recipes[element] {
+		if s.dependsOn(recipe.Ingredient1, element) || s.dependsOn(recipe.Ingredient2, element) {
+			continue
+		}
+
+		s.chosen[element] = recipe
+		var added []string
+		for _, ingredient := range []string{recipe.Ingredient1, recipe.Ingredient2} {
+			_, crafted := s.chosen[ingredient]
+			if !s.leaves[ingredient] && !crafted && !s.pending[ingredient] {
+				s.pending[ingredient] = true
+				added = append(added, ingredient)
+			}
+		}
+
+		s.run(target)
+
+		for _, ingredient := range added {
+			delete(s.pending, ingredient)
+		}
+		delete(s.chosen, element)
+		if s.err != nil {
+			break
+		}
+	}
+	s.pending[element] = true
+}
+
+var errDAGBudget = errors.New("DAG search budget exhausted")
+
+var errDAGCycle = errors.New("crafting DAG has a cycle")
+
+// expandDAG turns a DAG into the tree that crafts every occurrence again.
+// onPath holds the elements above element, meeting one again is a cycle
+func expandDAG(element string, chosen map[string]RecipeStep, onPath map[string]bool) (*TreeNode, error) {
+	node := &TreeNode{Element: element}
+	recipe, crafted := chosen[element]
+	if !crafted {
+		return node, nil
+	}
+	if onPath[element] {
+		return nil, errDAGCycle
+	}
+
+	onPath[element] = true
+	defer delete(onPath, element)
+	node.RecipeStep = &recipe
+	for _, ingredient := range []string{recipe.Ingredient1, recipe.Ingredient2} {
+		child, err := expandDAG(ingredient, chosen, onPath)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, child)
+	}
+	return node, nil
+}
+
+// treeActions counts the combinations of a tree, one per crafted node
+func treeActions(node *TreeNode) int {
+	if node.RecipeStep == nil {
+		return 0
+	}
+	count := 1
+	for _, child := range node.Children {
+		count += treeActions(child)
+	}
+	return count
+}
+
+// fallbackSeed is a first DAG for when the smallest tree loops: every required
+// element crafted on its own without target, and target crafted as if those
+// crafts were owned. nil when one of those trees does not exist
+func fallbackSeed(ctx context.Context, target string, graph map[string][][2]string, opts SearchOptions) map[string]RecipeStep {
+	// a DAG crafts every element once, so it never uses one on itself
+	acyclicGraph := make(map[string][][2]string, len(graph))
+	for result, pairs := range graph {
+		for _, pair := range pairs {
+			if pair[0] != result && pair[1] != result {
+				acyclicGraph[result] = append(acyclicGraph[result], pair)
+			}
+		}
+	}
+
+	// the required elements first, they never need target so their recipes win
+	seed := make(map[string]RecipeStep)
+	add := func(element string, opts SearchOptions) bool {
+		trees, err := solveMinimumTrees(ctx, acyclicGraph, opts, nodeCount, element, nil)
+		if err != nil {
+			return false
+		}
+		root, _, ok := trees.tree(element)
+		if !ok {
+			return false
+		}
+		for _, step := range orderedSteps([]*TreeNode{root}) {
+			if _, exists := seed[step.Result]; !exists {
+				seed[step.Result] = step
+			}
+		}
+		return true
+	}
+
+	leaves := opts.leaves()
+	targetOpts := opts
+	targetOpts.Inventory = append([]string{}, opts.Inventory...)
+	for _, element := range opts.Required {
+		if element == target || leaves[element] {
+			continue
+		}
+		elementOpts := SearchOptions{Inventory: opts.Inventory, Forbidden: append(append([]string{}, opts.Forbidden...), target)}
+		if !add(element, elementOpts) {
+			return nil
+		}
+	}
+	// owning everything crafted so far keeps target's tree from crafting any
+	// of it again with a recipe of its own
+	for element := range seed {
+		targetOpts.Inventory = append(targetOpts.Inventory, element)
+	}
+	if !add(target, targetOpts) {
+		return nil
+	}
+	return seed
+}
+
+// MinimalDAG finds the crafting DAG for target with the fewest unique
+// combinations over every recipe, ignoring tiers. it starts from the smallest
+// tree with every element crafted once and searches for fewer combinations
+// until it has proven the minimum, ran out of budget or ctx is done. the
+// best DAG found is returned with Optimal set only when the search finished,
+// and TimedOut set when ctx ran out first
+func MinimalDAG(ctx context.Context, target string, graph map[string][][2]string, opts SearchOptions, onEvent EventFunc) (CraftingDAG, *TreeNode, error) {
+	trees, err := solveMinimumTrees(ctx, graph, opts, nodeCount, "", nil)
+	if err != nil {
+		return CraftingDAG{}, nil, err
+	}
+	root, _, ok := trees.tree(target)
+	if !ok {
+		return CraftingDAG{}, nil, opts.uncraftable(target)
+	}
+
+	sizes := make(map[string]int)
+	for state, candidate := range trees.settled {
+		if size, ok := sizes[state.element]; !ok || int(candidate.cost) < size {
+			sizes[state.element] = int(candidate.cost)
+		}
+	}
+
+	// the smallest tree is the first DAG to beat. with required elements it can
+	// craft an element twice with different recipes, one of them using the
+	// element itself, so one recipe per element may loop or lose a required
+	// element. without a first DAG the search cannot prune, so fall back
+	search := newDAGSearch(ctx, graph, opts, sizes, onEvent)
+	start := make(map[string]RecipeStep)
+	for _, step := range orderedSteps([]*TreeNode{root}) {
+		start[step.Result] = step
+	}
+	if !search.seed(start, target) {
+		search.seed(fallbackSeed(ctx, target, graph, opts), target)
+	}
+	// reuse is measured against the first DAG crafted as a tree. that is the
+	// smallest tree unless it loops, then the fallback, which can be bigger.
+	// the search never returns more combinations than it started with
+	first := search.best
+
+	if !search.leaves[target] {
+		search.pending[target] = true
+		search.run(target)
+	}
+	// out of budget or time, the best DAG so far is still a valid one
+	if search.best == nil {
+		if search.err != nil && search.err != errDAGBudget {
+			return CraftingDAG{}, nil, search.err
+		}
+		return CraftingDAG{}, nil, opts.uncraftable(target)
+	}
+
+	tree, err := expandDAG(target, search.best, make(map[string]bool))
+	if err != nil {
+		return CraftingDAG{}, nil, err
+	}
+	firstTree := tree
+	if first != nil {
+		if firstTree, err = expandDAG(target, first, make(map[string]bool)); err != nil {
+			return CraftingDAG{}, nil, err
+		}
+	}
+	dag := CraftingDAG{
+		Target:      target,
+		Steps:       orderedSteps([]*TreeNode{tree}),
+		TreeActions: treeActions(firstTree),
+		Optimal:     search.err == nil,
+		TimedOut:    errors.Is(search.err, context.DeadlineExceeded),
+	}
+	dag.Actions = len(dag.Steps)
+	dag.Saved = dag.TreeActions - dag.Actions
+
+	// owned elements the DAG uses, by name
+	owned := make(map[string]bool)
+	for _, step := range dag.Steps {
+		for _, ingredient := range []string{step.Ingredient1, step.Ingredient2} {
+			if _, crafted := search.best[ingredient]; !crafted {
+				owned[ingredient] = true
+			}
+		}
+	}
+	var ownedNames []string
+	for element := range owned {
+		ownedNames = append(ownedNames, element)
+	}
+	sort.Strings(ownedNames)
+	for _, element := range ownedNames {
+		dag.Nodes = append(dag.Nodes, DAGNode{Element: element, Owned: true})
+	}
+	if len(dag.Steps) == 0 {
+		dag.Nodes = append(dag.Nodes, DAGNode{Element: target, Owned: true})
+	}
+
+	for i, step := range dag.Steps {
+		dag.Nodes = append(dag.Nodes, DAGNode{Element: step.Result, Step: i + 1})
+		dag.Edges = append(dag.Edges, DAGEdge{From: step.Ingredient1, To: step.Result})
+		if step.Ingredient2 != step.Ingredient1 {
+			dag.Edges = append(dag.Edges, DAGEdge{From: step.Ingredient2, To: step.Result})
+		}
+		onEvent.emitRecipe(step, target, search.expansions)
+	}
+	onEvent.emitTree(RecipePath{treeSteps(tree), tree}, search.expansions)
+	return dag, tree, nil
+}
+
+// SearchDAG is Dataset.Search for the minimal crafting DAG, Data is the DAG crafted as a tree
+func (d *Dataset) SearchDAG(ctx context.Context, target string, opts SearchOptions, onEvent EventFunc) (SearchResult, error) {
+	if err := opts.validate(d.Tiers); err != nil {
+		return SearchResult{}, err
+	}
+
+	fmt.Println("Finding minimal crafting DAG...")
+	dag, tree, err := MinimalDAG(ctx, target, d.Graph, opts, onEvent)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	path := RecipePath{treeSteps(tree), tree}
+	treeStats := calculateTreeStats(tree)
+	return SearchResult{Paths: []RecipePath{path}, NodeCount: treeStats.NodeCount, RecipeFound: 1, Optimal: dag.Optimal, TimedOut: dag.TimedOut, DAG: &dag}, nil
+}
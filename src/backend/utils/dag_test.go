@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMinimalDAG(t *testing.T) {
+	// Brick is needed twice by Wall, so reuse saves its crafts
+	reuseGraph := map[string][][2]string{
+		"Mud":   {{"Earth", "Water"}},
+		"Brick": {{"Mud", "Fire"}},
+		"Wall":  {{"Brick", "Brick"}, {"Brick", "Mud"}},
+		"Tower": {{"Wall", "Brick"}},
+	}
+	// with Mud required the smallest tree crafts Steam twice, once through
+	// Steam itself, which no DAG crafting every element once can do
+	selfGraph := map[string][][2]string{
+		"Steam": {{"Fire", "Water"}, {"Steam", "Mud"}},
+		"Mud":   {{"Earth", "Water"}},
+	}
+	// the same, with a longer way through Mud that never needs Steam
+	fallbackGraph := map[string][][2]string{
+		"Steam":    {{"Fire", "Water"}, {"Steam", "Mud"}, {"Mud", "Cloud"}},
+		"Mud":      {{"Earth", "Water"}},
+		"Cloud":    {{"Air", "Pressure"}},
+		"Pressure": {{"Air", "Air"}},
+	}
+
+	tests := []struct {
+		name        string
+		graph       map[string][][2]string
+		target      string
+		opts        SearchOptions
+		fails       bool // no DAG exists
+		actions     int
+		treeActions int
+	}{
+		{"leaf", reuseGraph, "Fire", SearchOptions{}, false, 0, 0},
+		{"one step", reuseGraph, "Mud", SearchOptions{}, false, 1, 1},
+		{"reuse", reuseGraph, "Tower", SearchOptions{}, false, 4, 7},
+		{"cycles", fixtureGraph, "Sea", SearchOptions{}, false, 6, 6},
+		{"required", fixtureGraph, "Rain", SearchOptions{Required: []string{"Mud"}}, false, 4, 4},
+		{"self-referential recipe", selfGraph, "Steam", SearchOptions{Required: []string{"Mud"}}, true, 0, 0},
+		// the 3 action smallest tree cannot be a DAG, reuse is measured against the fallback
+		{"smallest tree loops", fallbackGraph, "Steam", SearchOptions{Required: []string{"Mud"}}, false, 4, 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dag, tree, err := MinimalDAG(context.Background(), test.target, test.graph, test.opts, nil)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected no DAG, got %d actions", dag.Actions)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MinimalDAG: %v", err)
+			}
+
+			if !dag.Optimal {
+				t.Errorf("expected a proven minimum")
+			}
+			if dag.Actions != test.actions || dag.TreeActions != test.treeActions || dag.Saved != test.treeActions-test.actions {
+				t.Errorf("actions %d, treeActions %d, saved %d, want %d, %d, %d",
+					dag.Actions, dag.TreeActions, dag.Saved, test.actions, test.treeActions, test.treeActions-test.actions)
+			}
+
+			check := VerifySteps(test.graph, test.target, dag.Steps, test.opts)
+			if !check.Valid {
+				t.Errorf("steps are not a valid route: %s", check.Error)
+			}
+			checkTree(t, tree, test.graph, test.opts.leaves())
+			for _, element := range test.opts.Required {
+				if !treeContains(tree, element) {
+					t.Errorf("DAG does not contain required %s", element)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DatasetSource names a recipes file and the elements file that goes with it
+type DatasetSource struct {
+	Name     string `json:"name"`
+	Recipes  string `json:"recipes"`
+	Elements string `json:"elements"`
+}
+
+// Dataset is one loaded recipes file with its elements and indexes. it is
+// never modified after loading, so a request keeps using the dataset it
+// started with while a reload swaps in a new one
+type Dataset struct {
+	Graph    map[string][][2]string // result -> ingredient pairs
+	Tiers    map[string]int
+	Pairs    PairIndex
+	Uses     UsesIndex
+	Elements []Element // as listed in the elements file
+	Recipes  int       // number of recipes in the recipes file
+	Source   DatasetSource
+	LoadedAt time.Time
+}
+
+func newDataset(source DatasetSource, recipes []Recipe, elements []Element) *Dataset {
+	d := &Dataset{
+		Pairs:    make(PairIndex),
+		Uses:     make(UsesIndex),
+		Elements: elements,
+		Recipes:  len(recipes),
+		Source:   source,
+		LoadedAt: time.Now(),
+	}
+	d.Graph, d.Tiers = buildGraph(recipes)
+	for _, r := range recipes {
+		if len(r.Recipe) == 2 {
+			d.Pairs.add(r.Recipe[0], r.Recipe[1], r.Result)
+			d.Uses.add(RecipeStep{Ingredient1: r.Recipe[0], Ingredient2: r.Recipe[1], Result: r.Result})
+		}
+	}
+	return d
+}
+
+// datasets are registered once at startup, only what each one points to changes
+var (
+	datasets     = make(map[string]*atomic.Pointer[Dataset])
+	datasetNames []string // registration order, the first is the default
+)
+
+// loadValidDataset reads the files of source once and builds a dataset from
+// them, nil when the report has errors
+func loadValidDataset(source DatasetSource) (*Dataset, ValidationReport) {
+	recipes, elements, report := readDataset(source.Recipes, source.Elements)
+	if !report.HasErrors() {
+		report = validateRecipes(recipes, elements)
+	}
+	if report.HasErrors() {
+		return nil, report
+	}
+	return newDataset(source, recipes, elements), report
+}
+
+// RegisterDataset reads and validates source and serves it under its name.
+// a dataset with errors is not registered. call it before serving requests,
+// the first dataset registered is the default
+func RegisterDataset(source DatasetSource) (ValidationReport, error) {
+	if source.Name == "" {
+		return ValidationReport{}, fmt.Errorf("dataset for %s has no name", source.Recipes)
+	}
+	if _, exists := datasets[source.Name]; exists {
+		return ValidationReport{}, fmt.Errorf("dataset %s registered twice", source.Name)
+	}
+
+	d, report := loadValidDataset(source)
+	if d == nil {
+		return report, fmt.Errorf("dataset %s has errors", source.Name)
+	}
+	datasets[source.Name] = new(atomic.Pointer[Dataset])
+	datasets[source.Name].Store(d)
+	datasetNames = append(datasetNames, source.Name)
+	return report, nil
+}
+
+// ReloadDataset reads the files of a registered dataset again, validates what
+// it read and swaps that in. on errors the current one stays in place
+func ReloadDataset(name string) (*Dataset, ValidationReport, error) {
+	current, err := GetDataset(name)
+	if err != nil {
+		return nil, ValidationReport{}, err
+	}
+
+	source := current.Source
+	d, report := loadValidDataset(source)
+	if d == nil {
+		return nil, report, fmt.Errorf("dataset %s has errors, keeping the current one", source.Name)
+	}
+	datasets[source.Name].Store(d)
+	return d, report, nil
+}
+
+// GetDataset returns the current snapshot of the named dataset, the default one for ""
+func GetDataset(name string) (*Dataset, error) {
+	if name == "" {
+		if len(datasetNames) == 0 {
+			return nil, fmt.Errorf("no datasets loaded")
+		}
+		name = datasetNames[0]
+	}
+	slot, exists := datasets[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown dataset %s, expected one of %v", name, datasetNames)
+	}
+	return slot.Load(), nil
+}
+
+// Datasets returns the current snapshot of every dataset in registration order
+func Datasets() []*Dataset {
+	all := make([]*Dataset, 0, len(datasetNames))
+	for _, name := range datasetNames {
+		all = append(all, datasets[name].Load())
+	}
+	return all
+}
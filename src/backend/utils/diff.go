@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TreeSummary is the size of one side of a diff
+type TreeSummary struct {
+	NodeCount int `json:"nodeCount"`
+	Depth     int `json:"depth"`  // MaxDepth
+	Crafts    int `json:"crafts"` // distinct recipes used
+}
+
+// SharedSubtree is a crafted subtree both trees contain, ingredient order aside
+type SharedSubtree struct {
+	Element   string          `json:"element"`
+	NodeCount int             `json:"nodeCount"`
+	Tree      *JSONRecipeNode `json:"tree"`
+}
+
+// RecipeDifference is a node both trees reach that each crafts another way.
+// a nil recipe means that tree holds the element without crafting it
+type RecipeDifference struct {
+	Path    []string    `json:"path"` // elements from the root down to the node
+	Element string      `json:"element"`
+	Left    *RecipeStep `json:"left"`
+	Right   *RecipeStep `json:"right"`
+}
+
+// TreeDiff compares two trees for the same target. ingredient order never
+// counts as a difference
+type TreeDiff struct {
+	Identical     bool               `json:"identical"`
+	Left          TreeSummary        `json:"left"`
+	Right         TreeSummary        `json:"right"`
+	NodeCountDiff int                `json:"nodeCountDiff"` // right - left
+	DepthDiff     int                `json:"depthDiff"`     // right - left
+	Shared        []SharedSubtree    `json:"shared"`        // largest crafted subtrees in both, biggest first
+	Differences   []RecipeDifference `json:"differences"`   // walking down from the root, where the trees part
+}
+
+// subtreeKey identifies a subtree by its element and the ids of its two
+// ingredient subtrees, smallest first. a leaf has no ingredients, -1 twice
+type subtreeKey struct {
+	element     string
+	left, right int
+}
+
+// subtreeIDs numbers subtrees so equal ones get the same id, whatever the
+// order of their ingredients
+type subtreeIDs struct {
+	ids  map[subtreeKey]int
+	tree map[*TreeNode]int
+}
+
+func newSubtreeIDs() *subtreeIDs {
+	return &subtreeIDs{ids: make(map[subtreeKey]int), tree: make(map[*TreeNode]int)}
+}
+
+func (s *subtreeIDs) id(node *TreeNode) int {
+	if id, ok := s.tree[node]; ok {
+		return id
+	}
+
+	key := subtreeKey{node.Element, -1, -1}
+	if len(node.Children) == 2 {
+		key.left, key.right = s.id(node.Children[0]), s.id(node.Children[1])
+		if key.left > key.right {
+			key.left, key.right = key.right, key.left
+		}
+	}
+	id, ok := s.ids[key]
+	if !ok {
+		id = len(s.ids)
+		s.ids[key] = id
+	}
+	s.tree[node] = id
+	return id
+}
+
+func summarize(root *TreeNode) TreeSummary {
+	stats := calculateTreeStats(root)
+	return TreeSummary{NodeCount: stats.NodeCount, Depth: stats.MaxDepth, Crafts: len(treeSteps(root))}
+}
+
+// SmallestTree returns the tree with the fewest nodes, the first one on ties,
+// or nil when there is none. searchers do not all list their smallest tree first
+func SmallestTree(paths []RecipePath) *TreeNode {
+	var smallest *TreeNode
+	smallestCount := 0
+	for _, path := range paths {
+		if count := calculateTreeStats(path.TreeRoot).NodeCount; smallest == nil || count < smallestCount {
+			smallest, smallestCount = path.TreeRoot, count
+		}
+	}
+	return smallest
+}
+
+// DiffTrees compares left and right, which must craft the same element
+func DiffTrees(left, right *TreeNode) (TreeDiff, error) {
+	if left == nil || right == nil {
+		return TreeDiff{}, fmt.Errorf("two trees are needed")
+	}
+	if left.Element != right.Element {
+		return TreeDiff{}, fmt.Errorf("trees craft different elements %s and %s", left.Element, right.Element)
+	}
+
+	ids := newSubtreeIDs()
+	diff := TreeDiff{
+		Identical:   ids.id(left) == ids.id(right),
+		Left:        summarize(left),
+		Right:       summarize(right),
+		Shared:      []SharedSubtree{},
+		Differences: []RecipeDifference{},
+	}
+	diff.NodeCountDiff = diff.Right.NodeCount - diff.Left.NodeCount
+	diff.DepthDiff = diff.Right.Depth - diff.Left.Depth
+
+	// every crafted subtree of right, then the largest ones of left among them
+	inRight := make(map[int]bool)
+	var mark func(node *TreeNode)
+	mark = func(node *TreeNode) {
+		inRight[ids.id(node)] = true
+		for _, child := range node.Children {
+			mark(child)
+		}
+	}
+	mark(right)
+
+	reported := make(map[int]bool)
+	var share func(node *TreeNode)
+	share = func(node *TreeNode) {
+		if node.RecipeStep == nil {
+			return
+		}
+		id := ids.id(node)
+		if inRight[id] {
+			if !reported[id] {
+				reported[id] = true
+				diff.Shared = append(diff.Shared, SharedSubtree{
+					Element:   node.Element,
+					NodeCount: calculateTreeStats(node).NodeCount,
+					Tree:      _convertToJSONFormat(node),
+				})
+			}
+			return
+		}
+		for _, child := range node.Children {
+			share(child)
+		}
+	}
+	share(left)
+	sort.SliceStable(diff.Shared, func(i, j int) bool { return diff.Shared[i].NodeCount > diff.Shared[j].NodeCount })
+
+	// walk both trees together until they part
+	var compare func(a, b *TreeNode, path []string)
+	compare = func(a, b *TreeNode, path []string) {
+		path = append(path[:len(path):len(path)], a.Element)
+		if ids.id(a) == ids.id(b) {
+			return
+		}
+		if a.RecipeStep == nil || b.RecipeStep == nil || recipeCostKey(*a.RecipeStep) != recipeCostKey(*b.RecipeStep) {
+			diff.Differences = append(diff.Differences, RecipeDifference{Path: path, Element: a.Element, Left: a.RecipeStep, Right: b.RecipeStep})
+			return
+		}
+
+		// same ingredients, pair them by element whatever their order
+		left, right := a.Children, b.Children
+		if left[0].Element != right[0].Element || (ids.id(left[0]) != ids.id(right[0]) && ids.id(left[0]) == ids.id(right[1])) {
+			right = []*TreeNode{right[1], right[0]}
+		}
+		compare(left[0], right[0], path)
+		compare(left[1], right[1], path)
+	}
+	compare(left, right, nil)
+
+	return diff, nil
+}
+
+// treeFromJSON reads one tree in JSONRecipeNode format, at most one recipe per node
+func treeFromJSON(node *JSONRecipeNode) (*TreeNode, error) {
+	if node == nil || node.Name == "" {
+		return nil, fmt.Errorf("tree node without an element")
+	}
+	tree := &TreeNode{Element: node.Name}
+	if len(node.Recipes) == 0 {
+		return tree, nil
+	}
+	if len(node.Recipes) > 1 {
+		return nil, fmt.Errorf("%s has %d recipes, submit one tree at a time", node.Name, len(node.Recipes))
+	}
+
+	for _, ingredient := range node.Recipes[0] {
+		child, err := treeFromJSON(ingredient)
+		if err != nil {
+			return nil, err
+		}
+		tree.Children = append(tree.Children, child)
+	}
+	tree.RecipeStep = &RecipeStep{Ingredient1: tree.Children[0].Element, Ingredient2: tree.Children[1].Element, Result: node.Name}
+	return tree, nil
+}
+
+// DiffJSONTrees is DiffTrees for trees in JSONRecipeNode format
+func DiffJSONTrees(left, right *JSONRecipeNode) (TreeDiff, error) {
+	leftTree, err := treeFromJSON(left)
+	if err != nil {
+		return TreeDiff{}, fmt.Errorf("left tree: %w", err)
+	}
+	rightTree, err := treeFromJSON(right)
+	if err != nil {
+		return TreeDiff{}, fmt.Errorf("right tree: %w", err)
+	}
+	return DiffTrees(leftTree, rightTree)
+}
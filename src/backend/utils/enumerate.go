@@ -0,0 +1,273 @@
+package utils
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CanonicalTree serializes a tree so that trees differing only in ingredient
+// order (Fire+Water vs Water+Fire) get the same string
+func CanonicalTree(node *TreeNode) string {
+	return canonicalTree(node, make(map[*TreeNode]string))
+}
+
+func canonicalTree(node *TreeNode, memo map[*TreeNode]string) string {
+	if node == nil {
+		return ""
+	}
+	if cached, ok := memo[node]; ok {
+		return cached
+	}
+
+	result := strconv.Quote(node.Element)
+	if len(node.Children) > 0 {
+		parts := make([]string, len(node.Children))
+		for i, child := range node.Children {
+			parts[i] = canonicalTree(child, memo)
+		}
+		sort.Strings(parts)
+		result += "(" + strings.Join(parts, "+") + ")"
+	}
+
+	memo[node] = result
+	return result
+}
+
+// treeSteps lists every distinct recipe used in a tree in crafting order:
+// a recipe only comes after the recipes crafting its ingredients
+func treeSteps(root *TreeNode) []RecipeStep {
+	var steps []RecipeStep
+	seen := make(map[RecipeStep]bool)
+	visited := make(map[*TreeNode]bool) // subtrees are shared, walk each one once
+
+	var visit func(node *TreeNode)
+	visit = func(node *TreeNode) {
+		if node == nil || node.RecipeStep == nil || visited[node] {
+			return
+		}
+		visited[node] = true
+		for _, child := range node.Children {
+			visit(child)
+		}
+
+		key := *node.RecipeStep
+		pair := pairKey(key.Ingredient1, key.Ingredient2)
+		key.Ingredient1, key.Ingredient2 = pair[0], pair[1]
+		if !seen[key] {
+			seen[key] = true
+			steps = append(steps, *node.RecipeStep)
+		}
+	}
+	visit(root)
+	return steps
+}
+
+// treeEnumerator lists up to limit distinct trees per element from the
+// recipe variants a search found. trees are bucketed by the mask of required
+// elements they contain, with limit trees per bucket. subtrees are shared
+// between trees, so the returned trees must not be modified
+type treeEnumerator struct {
+	ctx            context.Context
+	recipeVariants map[string][]RecipeStep
+	leaves         map[string]bool
+	required       requirements
+	limit          int
+	memo           map[string]map[uint][]*TreeNode
+	inProgress     map[string]bool
+	ids            *subtreeIDs
+}
+
+func newTreeEnumerator(ctx context.Context, recipeVariants map[string][]RecipeStep, opts SearchOptions, limit int) *treeEnumerator {
+	return &treeEnumerator{
+		ctx:            ctx,
+		recipeVariants: recipeVariants,
+		leaves:         opts.leaves(),
+		required:       opts.requirements(),
+		limit:          limit,
+		memo:           make(map[string]map[uint][]*TreeNode),
+		inProgress:     make(map[string]bool),
+		ids:            newSubtreeIDs(),
+	}
+}
+
+func (e *treeEnumerator) trees(element string) map[uint][]*TreeNode {
+	if e.leaves[element] {
+		return map[uint][]*TreeNode{e.required.self(element): {{Element: element}}}
+	}
+	if cached, ok := e.memo[element]; ok {
+		return cached
+	}
+	// an element already being expanded would make a cycle
+	if e.inProgress[element] || e.ctx.Err() != nil {
+		return nil
+	}
+
+	e.inProgress[element] = true
+	result := make(map[uint][]*TreeNode)
+	seen := make(map[int]bool)
+	for _, variant := range e.recipeVariants[element] {
+		e.combine(variant, result, seen)
+		// without required elements there is only one bucket
+		if e.required.full == 0 && len(result[0]) >= e.limit {
+			break
+		}
+	}
+	delete(e.inProgress, element)
+
+	e.memo[element] = result
+	return result
+}
+
+// combine adds the trees for one recipe variant to result, skipping trees
+// whose subtree id is already in seen
+func (e *treeEnumerator) combine(variant RecipeStep, result map[uint][]*TreeNode, seen map[int]bool) {
+	left := e.trees(variant.Ingredient1)
+	right := e.trees(variant.Ingredient2)
+	self := e.required.self(variant.Result)
+
+	for _, leftMask := range sortedMasks(left) {
+		for _, rightMask := range sortedMasks(right) {
+			mask := leftMask | rightMask | self
+			for _, l := range left[leftMask] {
+				for _, r := range right[rightMask] {
+					if len(result[mask]) >= e.limit {
+						break
+					}
+
+					recipe := variant
+					node := &TreeNode{
+						Element:    variant.Result,
+						Children:   []*TreeNode{l, r},
+						RecipeStep: &recipe,
+					}
+					id := e.ids.id(node)
+					if seen[id] {
+						continue
+					}
+					seen[id] = true
+					result[mask] = append(result[mask], node)
+				}
+			}
+		}
+	}
+}
+
+func sortedMasks(buckets map[uint][]*TreeNode) []uint {
+	masks := make([]uint, 0, len(buckets))
+	for mask := range buckets {
+		masks = append(masks, mask)
+	}
+	sort.Slice(masks, func(i, j int) bool { return masks[i] < masks[j] })
+	return masks
+}
+
+// treeWorkers is how many top level variants BFS and DFS enumerate at once
+const treeWorkers = 3
+
+// buildRecipeTrees enumerates up to maxRecipes distinct trees for target that
+// contain every required element, ordered by number of steps then the order
+// they were found in. the top level variants are split across workers
+func buildRecipeTrees(ctx context.Context, target string, recipeVariants map[string][]RecipeStep, opts SearchOptions, maxRecipes, workers int, onEvent EventFunc, visitCount int) ([]RecipePath, error) {
+	variants := recipeVariants[target]
+	if limit := opts.variantLimit(maxRecipes); len(variants) > limit {
+		variants = variants[:limit]
+	}
+	full := opts.requirements().full
+
+	type variantTrees struct {
+		index int
+		trees []*TreeNode
+		steps [][]RecipeStep
+	}
+
+	// every top level variant is enumerated by its own worker, which also
+	// lists the steps of the trees it found
+	resultChan := make(chan variantTrees, len(variants))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+
+	for i, variant := range variants {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		wg.Add(1)
+		go func(index int, recipe RecipeStep) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			enumerator := newTreeEnumerator(ctx, recipeVariants, opts, maxRecipes)
+			enumerator.inProgress[target] = true
+			trees := make(map[uint][]*TreeNode)
+			enumerator.combine(recipe, trees, make(map[int]bool))
+			steps := make([][]RecipeStep, len(trees[full]))
+			for i, tree := range trees[full] {
+				if ctx.Err() != nil {
+					break
+				}
+				steps[i] = treeSteps(tree)
+			}
+			resultChan <- variantTrees{index, trees[full], steps}
+		}(i, variant)
+	}
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	byVariant := make([]variantTrees, len(variants))
+	for result := range resultChan {
+		byVariant[result.index] = result
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// every worker numbered its own subtrees, renumber them all together.
+	// ids grow in the order trees are visited, so they also order the ties
+	type candidate struct {
+		path RecipePath
+		id   int
+	}
+	var candidates []candidate
+	ids := newSubtreeIDs()
+	seen := make(map[int]bool)
+	for _, found := range byVariant {
+		for i, tree := range found.trees {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			id := ids.id(tree)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			candidates = append(candidates, candidate{RecipePath{found.steps[i], tree}, id})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if len(candidates[i].path.Steps) != len(candidates[j].path.Steps) {
+			return len(candidates[i].path.Steps) < len(candidates[j].path.Steps)
+		}
+		return candidates[i].id < candidates[j].id
+	})
+
+	if len(candidates) > maxRecipes {
+		candidates = candidates[:maxRecipes]
+	}
+
+	allPaths := make([]RecipePath, len(candidates))
+	for i, c := range candidates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		allPaths[i] = c.path
+		onEvent.emitTree(c.path, visitCount)
+	}
+	return allPaths, nil
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+)
+
+// exploration is what BFS, DFS and ParallelBFS track while discovering
+// recipes. they only differ in the order they visit elements, every recipe
+// they come across is recorded here the same way
+type exploration struct {
+	target         string
+	tiers          map[string]int
+	leaves         map[string]bool
+	forbidden      map[string]bool
+	policy         TierPolicy
+	variantLimit   int
+	pruned         *pruneCounter
+	onEvent        EventFunc
+	craftable      map[string]bool
+	recipeVariants map[string][]RecipeStep
+	recorded       map[RecipeStep]bool // recipeVariants, ingredients in pairKey order
+	discovered     map[string]int      // discovery order, see acyclicVariants
+	craftableList  []string            // craftable in exploration order
+	visits         int
+}
+
+func newExploration(target string, tiers map[string]int, maxRecipes int, opts SearchOptions, onEvent EventFunc) *exploration {
+	policy, _ := ParseTierPolicy(string(opts.Tier))
+	return &exploration{
+		target:         target,
+		tiers:          tiers,
+		leaves:         opts.leaves(),
+		forbidden:      opts.forbidden(),
+		policy:         policy,
+		variantLimit:   opts.variantLimit(maxRecipes),
+		pruned:         newPruneCounter(),
+		onEvent:        onEvent,
+		craftable:      make(map[string]bool),
+		recipeVariants: make(map[string][]RecipeStep),
+		recorded:       make(map[RecipeStep]bool),
+		discovered:     make(map[string]int),
+	}
+}
+
+// done reports whether the target has all the variants the search needs
+func (x *exploration) done() bool {
+	return len(x.recipeVariants[x.target]) >= x.variantLimit
+}
+
+func (x *exploration) visit(element string) {
+	x.visits++
+	x.onEvent.emit(SearchEvent{Type: EventVisit, Element: element, Visits: x.visits})
+}
+
+// discover marks element craftable, false when it already was
+func (x *exploration) discover(element string) bool {
+	if x.craftable[element] {
+		return false
+	}
+	x.craftable[element] = true
+	x.discovered[element] = len(x.discovered)
+	x.craftableList = append(x.craftableList, element)
+	return true
+}
+
+// recipeCheck is everything about one recipe that does not depend on what
+// the exploration found so far, so it can be worked out on any goroutine
+type recipeCheck struct {
+	forbidden bool
+	rejected  uint // see rejectingPolicies
+}
+
+func (x *exploration) check(current, ingredient, result string) recipeCheck {
+	if x.forbidden[result] {
+		return recipeCheck{forbidden: true}
+	}
+	return recipeCheck{rejected: rejectingPolicies(current, ingredient, result, x.tiers, x.leaves)}
+}
+
+func (c recipeCheck) rejectedBy(policy TierPolicy) bool {
+	for i, p := range TierPolicies {
+		if p == policy {
+			return c.rejected&(1<<i) != 0
+		}
+	}
+	return true
+}
+
+// record looks at current + ingredient = result, keeping it as a recipe
+// variant when the tier policy allows it. it returns true when result was
+// not craftable before, so the caller has to explore it
+func (x *exploration) record(current, ingredient, result string) bool {
+	return x.apply(current, ingredient, result, x.check(current, ingredient, result))
+}
+
+// apply is record with the recipe already checked
+func (x *exploration) apply(current, ingredient, result string, check recipeCheck) bool {
+	if check.forbidden {
+		return false
+	}
+	x.pruned.count(current, ingredient, result, check.rejected)
+	if check.rejectedBy(x.policy) {
+		return false
+	}
+
+	pair := pairKey(current, ingredient)
+	key := RecipeStep{Ingredient1: pair[0], Ingredient2: pair[1], Result: result}
+	if len(x.recipeVariants[result]) < x.variantLimit && !x.recorded[key] {
+		x.recorded[key] = true
+		newRecipe := RecipeStep{Ingredient1: current, Ingredient2: ingredient, Result: result}
+		x.recipeVariants[result] = append(x.recipeVariants[result], newRecipe)
+		x.onEvent.emitRecipe(newRecipe, x.target, x.visits)
+	}
+	return x.discover(result)
+}
+
+func (x *exploration) stats() SearchStats {
+	return SearchStats{x.visits, x.pruned.stats}
+}
+
+// trees builds up to maxRecipes trees for the target from what was recorded
+func (x *exploration) trees(ctx context.Context, opts SearchOptions, maxRecipes, workers int) ([]RecipePath, SearchStats, error) {
+	if !x.craftable[x.target] {
+		fmt.Printf("Cannot craft %s from starting elements\n", x.target)
+		return nil, x.stats(), nil
+	}
+
+	recipeVariants := acyclicVariants(x.recipeVariants, x.discovered, x.tiers, x.policy)
+	allPaths, err := buildRecipeTrees(ctx, x.target, recipeVariants, opts, maxRecipes, workers, x.onEvent, x.visits)
+	return allPaths, x.stats(), err
+}
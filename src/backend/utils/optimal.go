@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+)
+
+// an element together with the required elements its tree contains
+type sizeState struct {
+	element string
+	mask    uint
+}
+
+// candidate tree cost for a state, popped cheapest first. leaves have an empty recipe
+type sizeCandidate struct {
+	cost   float64
+	state  sizeState
+	recipe RecipeStep
+	masks  [2]uint // states of Ingredient1 and Ingredient2
+}
+
+type sizeQueue []sizeCandidate
+
+func (q sizeQueue) Len() int { return len(q) }
+func (q sizeQueue) Less(i, j int) bool {
+	if q[i].cost != q[j].cost {
+		return q[i].cost < q[j].cost
+	}
+	if q[i].state != q[j].state {
+		if q[i].state.element != q[j].state.element {
+			return q[i].state.element < q[j].state.element
+		}
+		return q[i].state.mask < q[j].state.mask
+	}
+	if q[i].recipe.Ingredient1 != q[j].recipe.Ingredient1 {
+		return q[i].recipe.Ingredient1 < q[j].recipe.Ingredient1
+	}
+	if q[i].recipe.Ingredient2 != q[j].recipe.Ingredient2 {
+		return q[i].recipe.Ingredient2 < q[j].recipe.Ingredient2
+	}
+	if q[i].masks[0] != q[j].masks[0] {
+		return q[i].masks[0] < q[j].masks[0]
+	}
+	return q[i].masks[1] < q[j].masks[1]
+}
+func (q sizeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *sizeQueue) Push(x interface{}) { *q = append(*q, x.(sizeCandidate)) }
+func (q *sizeQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// minimumTrees holds the cheapest tree for every settled state
+type minimumTrees struct {
+	settled  map[sizeState]sizeCandidate
+	required requirements
+}
+
+// solveMinimumTrees computes the cheapest crafting tree under weights (by
+// default counted in nodes, like calculateTreeStats) for every element
+// craftable from the leaves, per subset of required elements the tree contains.
+// the recipes form an AND-OR graph: an element is an OR over its recipes and a
+// recipe is an AND over its two ingredients. since a tree's cost is its root's
+// cost plus the cost of both subtrees, and no cost is negative, Knuth's
+// generalization of Dijkstra settles states in increasing cost order and every
+// settled cost is proven minimal, cycles included.
+// every recipe is considered, tier pruning does not apply here.
+// it stops early once stopAt is settled with every required element, "" runs to the end
+func solveMinimumTrees(ctx context.Context, graph map[string][][2]string, opts SearchOptions, weights treeWeights, stopAt string, onEvent EventFunc) (*minimumTrees, error) {
+	leaves := opts.leaves()
+	forbidden := opts.forbidden()
+	required := opts.requirements()
+
+	// ingredient -> recipes it is used in
+	usedIn := make(map[string][]RecipeStep)
+	for result, recipes := range graph {
+		// leaves are owned, never crafted
+		if forbidden[result] || leaves[result] {
+			continue
+		}
+		for _, recipe := range recipes {
+			if forbidden[recipe[0]] || forbidden[recipe[1]] {
+				continue
+			}
+			step := RecipeStep{Ingredient1: recipe[0], Ingredient2: recipe[1], Result: result}
+			usedIn[recipe[0]] = append(usedIn[recipe[0]], step)
+			if recipe[1] != recipe[0] {
+				usedIn[recipe[1]] = append(usedIn[recipe[1]], step)
+			}
+		}
+	}
+
+	trees := &minimumTrees{settled: make(map[sizeState]sizeCandidate), required: required}
+	settledMasks := make(map[string][]uint)
+	queue := &sizeQueue{}
+
+	for leaf := range leaves {
+		heap.Push(queue, sizeCandidate{cost: weights.node(leaf), state: sizeState{leaf, required.self(leaf)}})
+	}
+
+	visitCount := 0
+	for queue.Len() > 0 {
+		if visitCount%256 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		candidate := heap.Pop(queue).(sizeCandidate)
+		state := candidate.state
+		if _, settled := trees.settled[state]; settled {
+			continue
+		}
+
+		trees.settled[state] = candidate
+		settledMasks[state.element] = append(settledMasks[state.element], state.mask)
+		visitCount++
+		onEvent.emit(SearchEvent{Type: EventVisit, Element: state.element, Visits: visitCount})
+
+		if state.element == stopAt && state.mask == required.full {
+			break
+		}
+
+		for _, recipe := range usedIn[state.element] {
+			other := recipe.Ingredient2
+			if other == state.element {
+				other = recipe.Ingredient1
+			}
+
+			for _, otherMask := range settledMasks[other] {
+				next := sizeState{recipe.Result, state.mask | otherMask | required.self(recipe.Result)}
+				if _, settled := trees.settled[next]; settled {
+					continue
+				}
+
+				masks := [2]uint{state.mask, otherMask}
+				if recipe.Ingredient1 != state.element {
+					masks = [2]uint{otherMask, state.mask}
+				}
+				cost := weights.node(recipe.Result) + weights.craft(recipe) + candidate.cost + trees.settled[sizeState{other, otherMask}].cost
+				heap.Push(queue, sizeCandidate{cost: cost, state: next, recipe: recipe, masks: masks})
+			}
+		}
+	}
+
+	return trees, nil
+}
+
+// tree rebuilds the cheapest tree for element containing every required element
+func (t *minimumTrees) tree(element string) (*TreeNode, float64, bool) {
+	state := sizeState{element, t.required.full}
+	candidate, ok := t.settled[state]
+	if !ok {
+		return nil, 0, false
+	}
+	return t.build(state), candidate.cost, true
+}
+
+func (t *minimumTrees) build(state sizeState) *TreeNode {
+	candidate := t.settled[state]
+	node := &TreeNode{Element: state.element}
+	if candidate.recipe.Result == "" {
+		return node
+	}
+
+	recipe := candidate.recipe
+	node.RecipeStep = &recipe
+	node.Children = []*TreeNode{
+		t.build(sizeState{recipe.Ingredient1, candidate.masks[0]}),
+		t.build(sizeState{recipe.Ingredient2, candidate.masks[1]}),
+	}
+	return node
+}
+
+// OptimalSearch returns the smallest possible crafting tree for target and its node count
+func OptimalSearch(ctx context.Context, target string, graph map[string][][2]string, opts SearchOptions, onEvent EventFunc) (RecipePath, int, error) {
+	trees, err := solveMinimumTrees(ctx, graph, opts, nodeCount, target, onEvent)
+	if err != nil {
+		return RecipePath{}, 0, err
+	}
+
+	root, size, ok := trees.tree(target)
+	if !ok {
+		return RecipePath{}, 0, opts.uncraftable(target)
+	}
+
+	path := RecipePath{treeSteps(root), root}
+	onEvent.emitTree(path, len(trees.settled))
+	return path, int(size), nil
+}
+
+// SearchOptimal is Dataset.Search for the exact minimum tree, it always returns one tree
+func (d *Dataset) SearchOptimal(ctx context.Context, target string, opts SearchOptions, onEvent EventFunc) (SearchResult, error) {
+	if err := opts.validate(d.Tiers); err != nil {
+		return SearchResult{}, err
+	}
+
+	fmt.Println("Finding optimal recipe...")
+	path, size, err := OptimalSearch(ctx, target, d.Graph, opts, onEvent)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return SearchResult{Paths: []RecipePath{path}, NodeCount: size, RecipeFound: 1, Optimal: true}, nil
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"context"
+	"testing"
+)
+
+// a small graph with two cycles: Cloud <-> Rain and Lake <-> Sea. the minimum
+// sizes without constraints are Steam 3, Mud 3, Cloud 5, Rain 7, Lake 11, Sea 13
+var fixtureGraph = map[string][][2]string{
+	"Steam": {{"Fire", "Water"}, {"Mud", "Fire"}},
+	"Mud":   {{"Earth", "Water"}},
+	"Cloud": {{"Steam", "Air"}, {"Rain", "Rain"}},
+	"Rain":  {{"Cloud", "Water"}},
+	"Lake":  {{"Rain", "Mud"}, {"Sea", "Earth"}},
+	"Sea":   {{"Lake", "Water"}},
+}
+
+// treeContains reports whether element appears anywhere in the tree
+func treeContains(node *TreeNode, element string) bool {
+	if node.Element == element {
+		return true
+	}
+	for _, child := range node.Children {
+		if treeContains(child, element) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTree fails unless every crafted node uses a recipe of graph and every
+// uncrafted node is a leaf
+func checkTree(t *testing.T, node *TreeNode, graph map[string][][2]string, leaves map[string]bool) {
+	t.Helper()
+	if node.RecipeStep == nil {
+		if !leaves[node.Element] {
+			t.Errorf("%s is not crafted and not a leaf", node.Element)
+		}
+		return
+	}
+	if len(node.Children) != 2 || !hasRecipe(graph, *node.RecipeStep) {
+		t.Errorf("%s uses a recipe that is not in the graph: %+v", node.Element, *node.RecipeStep)
+		return
+	}
+	for _, child := range node.Children {
+		checkTree(t, child, graph, leaves)
+	}
+}
+
+func TestSolveMinimumTrees(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		opts   SearchOptions
+		size   int // 0 when target cannot be crafted
+	}{
+		{"leaf", "Fire", SearchOptions{}, 1},
+		{"one step", "Steam", SearchOptions{}, 3},
+		{"through a cycle", "Rain", SearchOptions{}, 7},
+		{"two cycles", "Lake", SearchOptions{}, 11},
+		{"deepest", "Sea", SearchOptions{}, 13},
+		{"inventory", "Lake", SearchOptions{Inventory: []string{"Cloud"}}, 7},
+		{"required already on the way", "Lake", SearchOptions{Required: []string{"Mud"}}, 11},
+		{"required forces a bigger recipe", "Steam", SearchOptions{Required: []string{"Mud"}}, 5},
+		{"required deep below", "Rain", SearchOptions{Required: []string{"Mud"}}, 9},
+		{"two required", "Sea", SearchOptions{Required: []string{"Mud", "Steam"}}, 13},
+		{"required not reachable", "Mud", SearchOptions{Required: []string{"Steam"}}, 0},
+		{"only cycles left", "Lake", SearchOptions{Forbidden: []string{"Steam"}}, 0},
+		{"forbidden base element", "Steam", SearchOptions{Forbidden: []string{"Water"}}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trees, err := solveMinimumTrees(context.Background(), fixtureGraph, test.opts, nodeCount, "", nil)
+			if err != nil {
+				t.Fatalf("solveMinimumTrees: %v", err)
+			}
+
+			root, size, ok := trees.tree(test.target)
+			if test.size == 0 {
+				if ok {
+					t.Fatalf("expected %s to be uncraftable, got a tree of size %v", test.target, size)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("expected a tree for %s", test.target)
+			}
+			if int(size) != test.size {
+				t.Errorf("size = %v, want %d", size, test.size)
+			}
+			if nodes := calculateTreeStats(root).NodeCount; nodes != test.size {
+				t.Errorf("tree has %d nodes, want %d", nodes, test.size)
+			}
+
+			checkTree(t, root, fixtureGraph, test.opts.leaves())
+			for _, element := range test.opts.Required {
+				if !treeContains(root, element) {
+					t.Errorf("tree does not contain required %s", element)
+				}
+			}
+			for _, element := range test.opts.Forbidden {
+				if treeContains(root, element) {
+					t.Errorf("tree contains forbidden %s", element)
+				}
+			}
+		})
+	}
+}
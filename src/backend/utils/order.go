@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// explorationOrder is the order BFS and DFS try elements in. by default that is
+// the starting elements by name followed by everything else in discovery order,
+// so the same query always explores, and returns, the same way. with a seed
+// every list is shuffled by a generator of its own, random but replayable
+type explorationOrder struct {
+	rng *rand.Rand
+}
+
+func (opts SearchOptions) explorationOrder() explorationOrder {
+	if opts.Seed == nil {
+		return explorationOrder{}
+	}
+	return explorationOrder{rng: rand.New(rand.NewSource(*opts.Seed))}
+}
+
+// arrange returns a copy of elements in exploration order
+func (o explorationOrder) arrange(elements []string) []string {
+	arranged := append([]string(nil), elements...)
+	if o.rng != nil {
+		o.rng.Shuffle(len(arranged), func(i, j int) {
+			arranged[i], arranged[j] = arranged[j], arranged[i]
+		})
+	}
+	return arranged
+}
+
+// start lists the leaves a search begins from
+func (o explorationOrder) start(leaves map[string]bool) []string {
+	start := make([]string, 0, len(leaves))
+	for leaf := range leaves {
+		start = append(start, leaf)
+	}
+	sort.Strings(start)
+	return o.arrange(start)
+}
+
+// indices returns the order arrange would put n elements in, as their
+// indices, drawing the same numbers from the generator
+func (o explorationOrder) indices(n int) []int {
+	index := make([]int, n)
+	for i := range index {
+		index[i] = i
+	}
+	if o.rng != nil {
+		o.rng.Shuffle(n, func(i, j int) {
+			index[i], index[j] = index[j], index[i]
+		})
+	}
+	return index
+}
+
+// positions returns where each of n elements would land if arrange
+// were called on them, drawing the same numbers from the generator
+func (o explorationOrder) positions(n int) []int {
+	positions := make([]int, n)
+	for position, i := range o.indices(n) {
+		positions[i] = position
+	}
+	return positions
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// pairHit is one craftable element that combines with the element being
+// expanded, with every recipe the pair makes already checked
+type pairHit struct {
+	index   int           // position of the ingredient in the craftable list
+	results []string      // what the pair crafts
+	checks  []recipeCheck // one per result
+}
+
+// pairHits checks what current makes with each of craftable, the first of
+// which sits at offset in the craftable list. it only reads what never
+// changes during a search, so workers can call it while the merge runs
+func (x *exploration) pairHits(current string, craftable []string, offset int, index PairIndex) []pairHit {
+	var hits []pairHit
+	for j, ingredient := range craftable {
+		results := findRecipes(current, ingredient, index)
+		if len(results) == 0 {
+			continue
+		}
+		checks := make([]recipeCheck, len(results))
+		for k, result := range results {
+			checks[k] = x.check(current, ingredient, result)
+		}
+		hits = append(hits, pairHit{offset + j, results, checks})
+	}
+	return hits
+}
+
+// expandLevel hands the elements of a frontier level to workers in queue
+// order, each one checked against the known craftable elements. an element's
+// hits can be read from its channel as soon as they are ready, so the merge
+// starts on the first element while the workers go on with the rest. call
+// stop once the level is merged
+func (x *exploration) expandLevel(level, known []string, index PairIndex, workers int) (hits []chan []pairHit, stop func()) {
+	hits = make([]chan []pairHit, len(level))
+	for i := range hits {
+		hits[i] = make(chan []pairHit, 1)
+	}
+
+	next := make(chan int)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(level)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				hits[i] <- x.pairHits(level[i], known, 0, index)
+			}
+		}()
+	}
+	go func() {
+		defer close(next)
+		for i := range level {
+			select {
+			case next <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return hits, func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// mergeLevel records what every element of level makes, one element at a time
+// in queue order, and returns the next level. the workers checked each element
+// against what was known when the level started, the elements discovered
+// earlier in the same level are checked here, like BFS would
+func (x *exploration) mergeLevel(ctx context.Context, level []string, index PairIndex, order explorationOrder, workers int) ([]string, error) {
+	known := len(x.craftableList)
+	hits, stop := x.expandLevel(level, x.craftableList[:known:known], index, workers)
+	defer stop()
+
+	var queue []string
+	for i, current := range level {
+		if x.done() {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		x.visit(current)
+
+		currentHits := <-hits[i]
+		currentHits = append(currentHits, x.pairHits(current, x.craftableList[known:], known, index)...)
+		if order.rng != nil {
+			positions := order.positions(len(x.craftableList))
+			sort.Slice(currentHits, func(a, b int) bool {
+				return positions[currentHits[a].index] < positions[currentHits[b].index]
+			})
+		}
+
+		for _, hit := range currentHits {
+			ingredient := x.craftableList[hit.index]
+			for _, k := range order.indices(len(hit.results)) {
+				if x.apply(current, ingredient, hit.results[k], hit.checks[k]) {
+					queue = append(queue, hit.results[k])
+				}
+			}
+		}
+	}
+	return queue, nil
+}
+
+// ParallelBFS is BFS with each frontier level expanded by a pool of
+// opts.Workers goroutines. the workers look up which known elements every
+// frontier element combines with and check each recipe against the forbidden
+// elements and every tier policy. what they found is merged one element at a
+// time in queue order, so recipes, events and trees match BFS exactly, while
+// the workers carry on with the elements after it. the trees are then built
+// by the same number of workers
+func ParallelBFS(ctx context.Context, target string, index PairIndex, tiers map[string]int, maxRecipes int, opts SearchOptions, onEvent EventFunc) ([]RecipePath, SearchStats, error) {
+	x := newExploration(target, tiers, maxRecipes, opts, onEvent)
+	order := opts.explorationOrder()
+	workers := opts.workers()
+
+	queue := order.start(x.leaves)
+	for _, base := range queue {
+		x.discover(base)
+	}
+
+	for len(queue) > 0 && !x.done() {
+		var err error
+		if queue, err = x.mergeLevel(ctx, queue, index, order, workers); err != nil {
+			return nil, x.stats(), err
+		}
+	}
+
+	return x.trees(ctx, opts, maxRecipes, workers)
+}
+
+// workers is the size of the ParallelBFS worker pool, one per CPU by default
+// and never more
+func (opts SearchOptions) workers() int {
+	if opts.Workers > 0 {
+		return min(opts.Workers, runtime.NumCPU())
+	}
+	return runtime.NumCPU()
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// several recipes per element, recipes going down in tier and cycles, so the
+// tier policies and the exploration order all make a difference
+var fixtureRecipes = []Recipe{
+	{Tier: 1, Result: "Steam", Recipe: []string{"Fire", "Water"}},
+	{Tier: 1, Result: "Mud", Recipe: []string{"Earth", "Water"}},
+	{Tier: 1, Result: "Lava", Recipe: []string{"Earth", "Fire"}},
+	{Tier: 1, Result: "Pressure", Recipe: []string{"Air", "Air"}},
+	{Tier: 1, Result: "Puddle", Recipe: []string{"Water", "Water"}},
+	{Tier: 1, Result: "Energy", Recipe: []string{"Fire", "Fire"}},
+	{Tier: 2, Result: "Stone", Recipe: []string{"Lava", "Air"}},
+	{Tier: 2, Result: "Stone", Recipe: []string{"Earth", "Pressure"}},
+	{Tier: 2, Result: "Cloud", Recipe: []string{"Steam", "Air"}},
+	{Tier: 2, Result: "Cloud", Recipe: []string{"Air", "Puddle"}},
+	{Tier: 2, Result: "Pond", Recipe: []string{"Puddle", "Water"}},
+	{Tier: 3, Result: "Rain", Recipe: []string{"Cloud", "Water"}},
+	{Tier: 3, Result: "Clay", Recipe: []string{"Mud", "Stone"}},
+	{Tier: 3, Result: "Brick", Recipe: []string{"Mud", "Fire"}},
+	{Tier: 3, Result: "Brick", Recipe: []string{"Stone", "Energy"}},
+	{Tier: 2, Result: "Cloud", Recipe: []string{"Rain", "Rain"}},
+	{Tier: 4, Result: "Brick", Recipe: []string{"Clay", "Fire"}},
+	{Tier: 4, Result: "Lake", Recipe: []string{"Pond", "Water"}},
+	{Tier: 4, Result: "Lake", Recipe: []string{"Rain", "Pond"}},
+	{Tier: 4, Result: "Wall", Recipe: []string{"Brick", "Brick"}},
+	{Tier: 4, Result: "Wall", Recipe: []string{"Stone", "Clay"}},
+	{Tier: 5, Result: "Sea", Recipe: []string{"Lake", "Water"}},
+	{Tier: 4, Result: "Lake", Recipe: []string{"Sea", "Earth"}},
+	{Tier: 5, Result: "House", Recipe: []string{"Wall", "Wall"}},
+	{Tier: 5, Result: "House", Recipe: []string{"Wall", "Clay"}},
+	{Tier: 6, Result: "Village", Recipe: []string{"House", "House"}},
+	{Tier: 6, Result: "Village", Recipe: []string{"House", "Lake"}},
+}
+
+// searchTrace is everything a search returns and emits, for comparing two searches
+type searchTrace struct {
+	trees       []string
+	steps       [][]RecipeStep
+	nodeCount   int
+	recipeFound int
+	discarded   PruneStats
+	events      []SearchEvent
+	err         error
+}
+
+func traceSearch(data *Dataset, traverse Traversal, req SearchRequest) searchTrace {
+	var trace searchTrace
+	result, err := data.Search(context.Background(), traverse, req, func(event SearchEvent) {
+		trace.events = append(trace.events, event)
+	})
+	trace.err = err
+	for _, path := range result.Paths {
+		trace.trees = append(trace.trees, CanonicalTree(path.TreeRoot))
+		trace.steps = append(trace.steps, path.Steps)
+	}
+	trace.nodeCount = result.NodeCount
+	trace.recipeFound = result.RecipeFound
+	trace.discarded = result.Discarded
+	return trace
+}
+
+func TestParallelBFSMatchesBFS(t *testing.T) {
+	data := newDataset(DatasetSource{Name: "fixture"}, fixtureRecipes, nil)
+	seed1, seed42 := int64(1), int64(42)
+
+	tests := []struct {
+		name string
+		req  SearchRequest
+	}{
+		{"shortest", SearchRequest{Target: "Brick", FindShortest: true, MaxRecipes: 1}},
+		{"several trees", SearchRequest{Target: "House", MaxRecipes: 5}},
+		{"deep target", SearchRequest{Target: "Village", MaxRecipes: 10}},
+		{"nonstrict", SearchRequest{Target: "Lake", MaxRecipes: 5, Options: SearchOptions{Tier: TierNonStrict}}},
+		{"no tier pruning", SearchRequest{Target: "Sea", MaxRecipes: 5, Options: SearchOptions{Tier: TierNone}}},
+		{"inventory and forbidden", SearchRequest{Target: "Wall", MaxRecipes: 5, Options: SearchOptions{Inventory: []string{"Stone"}, Forbidden: []string{"Energy"}}}},
+		{"required", SearchRequest{Target: "House", MaxRecipes: 5, Options: SearchOptions{Required: []string{"Clay"}}}},
+		{"uncraftable", SearchRequest{Target: "Brick", MaxRecipes: 5, Options: SearchOptions{Forbidden: []string{"Mud", "Stone"}}}},
+		{"seed", SearchRequest{Target: "Village", MaxRecipes: 10, Options: SearchOptions{Seed: &seed1}}},
+		{"seed shortest", SearchRequest{Target: "Wall", FindShortest: true, MaxRecipes: 1, Options: SearchOptions{Seed: &seed42}}},
+		{"seed no tier pruning", SearchRequest{Target: "Lake", MaxRecipes: 5, Options: SearchOptions{Seed: &seed42, Tier: TierNone}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := traceSearch(data, BFS, test.req)
+			for _, workers := range []int{1, 2, 8} {
+				req := test.req
+				req.Options.Workers = workers
+				got := traceSearch(data, ParallelBFS, req)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("workers=%d: PARALLEL_BFS differs from BFS\n got: %+v\nwant: %+v", workers, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ParetoPoint is one tree of the depth versus size front with its stats
+type ParetoPoint struct {
+	Depth     int             `json:"depth"`     // MaxDepth, crafting rounds + 1
+	NodeCount int             `json:"nodeCount"` // nodes in the tree
+	Crafts    int             `json:"crafts"`    // distinct recipes used
+	Tree      *JSONRecipeNode `json:"tree"`
+}
+
+// depthLayers holds, per depth bound, the smallest tree for every state whose
+// tree fits within it. layers[i] allows MaxDepth i + 1, so only leaves fit layers[0]
+type depthLayers struct {
+	layers   []map[sizeState]sizeCandidate
+	required requirements
+	visits   int // state improvements over all layers
+}
+
+// solveDepthLayers computes the smallest tree per state for every depth bound
+// until a deeper bound no longer gives a smaller tree anywhere. a tree within
+// depth d crafts its root from two trees within depth d - 1, so every layer
+// follows from the one before it. every recipe is considered, like
+// solveMinimumTrees, tier pruning does not apply here
+func solveDepthLayers(ctx context.Context, graph map[string][][2]string, opts SearchOptions, onEvent EventFunc) (*depthLayers, error) {
+	leaves := opts.leaves()
+	forbidden := opts.forbidden()
+	required := opts.requirements()
+
+	// in a fixed order so ties always go to the same recipe
+	results := make([]string, 0, len(graph))
+	for result := range graph {
+		if !forbidden[result] && !leaves[result] {
+			results = append(results, result)
+		}
+	}
+	sort.Strings(results)
+	var recipes []RecipeStep
+	for _, result := range results {
+		for _, recipe := range graph[result] {
+			if !forbidden[recipe[0]] && !forbidden[recipe[1]] {
+				recipes = append(recipes, RecipeStep{Ingredient1: recipe[0], Ingredient2: recipe[1], Result: result})
+			}
+		}
+	}
+
+	layer := make(map[sizeState]sizeCandidate)
+	for leaf := range leaves {
+		state := sizeState{leaf, required.self(leaf)}
+		layer[state] = sizeCandidate{cost: 1, state: state}
+	}
+	d := &depthLayers{layers: []map[sizeState]sizeCandidate{layer}, required: required}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		masks := make(map[string][]uint)
+		for state := range layer {
+			masks[state.element] = append(masks[state.element], state.mask)
+		}
+		for _, elementMasks := range masks {
+			sort.Slice(elementMasks, func(i, j int) bool { return elementMasks[i] < elementMasks[j] })
+		}
+
+		next := make(map[sizeState]sizeCandidate, len(layer))
+		for state, candidate := range layer {
+			next[state] = candidate
+		}
+		var improved []sizeState // in the order they first improved
+		isImproved := make(map[sizeState]bool)
+		for _, recipe := range recipes {
+			for _, leftMask := range masks[recipe.Ingredient1] {
+				for _, rightMask := range masks[recipe.Ingredient2] {
+					state := sizeState{recipe.Result, leftMask | rightMask | required.self(recipe.Result)}
+					size := 1 + layer[sizeState{recipe.Ingredient1, leftMask}].cost + layer[sizeState{recipe.Ingredient2, rightMask}].cost
+					if current, exists := next[state]; exists && current.cost <= size {
+						continue
+					}
+					if !isImproved[state] {
+						isImproved[state] = true
+						improved = append(improved, state)
+					}
+					next[state] = sizeCandidate{cost: size, state: state, recipe: recipe, masks: [2]uint{leftMask, rightMask}}
+				}
+			}
+		}
+		if len(improved) == 0 {
+			return d, nil
+		}
+
+		for _, state := range improved {
+			d.visits++
+			onEvent.emit(SearchEvent{Type: EventVisit, Element: state.element, Visits: d.visits})
+		}
+		d.layers = append(d.layers, next)
+		layer = next
+	}
+}
+
+// build rebuilds the smallest tree for state within layers[depth]
+func (d *depthLayers) build(depth int, state sizeState) *TreeNode {
+	candidate := d.layers[depth][state]
+	node := &TreeNode{Element: state.element}
+	if candidate.recipe.Result == "" {
+		return node
+	}
+
+	recipe := candidate.recipe
+	node.RecipeStep = &recipe
+	node.Children = []*TreeNode{
+		d.build(depth-1, sizeState{recipe.Ingredient1, candidate.masks[0]}),
+		d.build(depth-1, sizeState{recipe.Ingredient2, candidate.masks[1]}),
+	}
+	return node
+}
+
+// ParetoSearch returns the trees for target that trade depth against node
+// count: for every depth where the smallest tree gets smaller, the smallest
+// tree within it. they come shallowest first, so sizes strictly decrease
+func ParetoSearch(ctx context.Context, target string, graph map[string][][2]string, opts SearchOptions, onEvent EventFunc) ([]RecipePath, error) {
+	d, err := solveDepthLayers(ctx, graph, opts, onEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	var front []RecipePath
+	state := sizeState{target, d.required.full}
+	smallest := math.Inf(1)
+	for depth, layer := range d.layers {
+		candidate, ok := layer[state]
+		if !ok || candidate.cost >= smallest {
+			continue
+		}
+		smallest = candidate.cost
+
+		root := d.build(depth, state)
+		path := RecipePath{treeSteps(root), root}
+		front = append(front, path)
+		onEvent.emitTree(path, d.visits)
+	}
+
+	if len(front) == 0 {
+		return nil, opts.uncraftable(target)
+	}
+	return front, nil
+}
+
+// SearchPareto is Dataset.Search for the depth versus size front, shortest and max do not apply
+func (d *Dataset) SearchPareto(ctx context.Context, target string, opts SearchOptions, onEvent EventFunc) (SearchResult, error) {
+	if err := opts.validate(d.Tiers); err != nil {
+		return SearchResult{}, err
+	}
+
+	fmt.Println("Finding depth versus size front...")
+	front, err := ParetoSearch(ctx, target, d.Graph, opts, onEvent)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	result := SearchResult{Paths: front, RecipeFound: len(front), Optimal: true}
+	for _, path := range front {
+		stats := calculateTreeStats(path.TreeRoot)
+		result.NodeCount += stats.NodeCount
+		result.Pareto = append(result.Pareto, ParetoPoint{
+			Depth:     stats.MaxDepth,
+			NodeCount: stats.NodeCount,
+			Crafts:    len(path.Steps),
+			Tree:      _convertToJSONFormat(path.TreeRoot),
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CraftingPlan crafts several targets in one session, every element is crafted
+// once and reused by every target that needs it
+type CraftingPlan struct {
+	Targets        []string          `json:"targets"`
+	Trees          []*JSONRecipeNode `json:"trees"`          // one tree per target, same order
+	Steps          []RecipeStep      `json:"steps"`          // unique crafts, ingredients always come first
+	Shared         []string          `json:"shared"`         // crafted elements needed by more than one target
+	UniqueCrafts   int               `json:"uniqueCrafts"`   // crafts for the whole plan
+	SeparateCrafts int               `json:"separateCrafts"` // crafts if every target was done on its own
+}
+
+// orderedSteps lists the recipes of the trees in crafting order, every element
+// once. the trees must use one recipe per element
+func orderedSteps(roots []*TreeNode) []RecipeStep {
+	var steps []RecipeStep
+	done := make(map[string]bool)
+
+	var visit func(node *TreeNode)
+	visit = func(node *TreeNode) {
+		if node == nil || node.RecipeStep == nil || done[node.Element] {
+			return
+		}
+		for _, child := range node.Children {
+			visit(child)
+		}
+		done[node.Element] = true
+		steps = append(steps, *node.RecipeStep)
+	}
+
+	for _, root := range roots {
+		visit(root)
+	}
+	return steps
+}
+
+// PlanTargets builds one crafting plan for every target. each element uses its
+// recipe from the smallest tree, so trees for different targets agree on every
+// shared element and it only has to be crafted once
+func PlanTargets(ctx context.Context, targets []string, graph map[string][][2]string, opts SearchOptions) (CraftingPlan, error) {
+	if len(opts.Required) > 0 {
+		return CraftingPlan{}, fmt.Errorf("required elements are not supported for crafting plans")
+	}
+
+	trees, err := solveMinimumTrees(ctx, graph, opts, nodeCount, "", nil)
+	if err != nil {
+		return CraftingPlan{}, err
+	}
+
+	plan := CraftingPlan{Targets: targets}
+	var roots []*TreeNode
+	var missing []string
+	for _, target := range targets {
+		root, _, ok := trees.tree(target)
+		if !ok {
+			missing = append(missing, target)
+			continue
+		}
+		roots = append(roots, root)
+	}
+	if len(missing) > 0 {
+		return CraftingPlan{}, fmt.Errorf("cannot craft %s", strings.Join(missing, ", "))
+	}
+
+	// count how many targets need each crafted element
+	neededBy := make(map[string]int)
+	for _, root := range roots {
+		steps := orderedSteps([]*TreeNode{root})
+		plan.SeparateCrafts += len(steps)
+		for _, step := range steps {
+			neededBy[step.Result]++
+		}
+	}
+
+	plan.Steps = orderedSteps(roots)
+	plan.UniqueCrafts = len(plan.Steps)
+	for _, root := range roots {
+		plan.Trees = append(plan.Trees, _convertToJSONFormat(root))
+	}
+
+	plan.Shared = []string{}
+	for element, count := range neededBy {
+		if count > 1 {
+			plan.Shared = append(plan.Shared, element)
+		}
+	}
+	sort.Strings(plan.Shared)
+
+	return plan, nil
+}
+
+// SearchPlan is PlanTargets over the dataset, a target listed twice is planned once
+func (d *Dataset) SearchPlan(ctx context.Context, targets []string, opts SearchOptions) (CraftingPlan, error) {
+	if len(targets) == 0 {
+		return CraftingPlan{}, fmt.Errorf("no targets given")
+	}
+	unique := make([]string, 0, len(targets))
+	listed := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if _, exists := d.Tiers[target]; !exists {
+			return CraftingPlan{}, fmt.Errorf("unknown element %s", target)
+		}
+		if !listed[target] {
+			listed[target] = true
+			unique = append(unique, target)
+		}
+	}
+	targets = unique
+	if err := opts.validate(d.Tiers); err != nil {
+		return CraftingPlan{}, err
+	}
+
+	fmt.Printf("Planning crafts for %s...\n", strings.Join(targets, ", "))
+	return PlanTargets(ctx, targets, d.Graph, opts)
+}
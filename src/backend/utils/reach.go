@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"sort"
+)
+
+type Discovery struct {
+	Element string       `json:"element"`
+	Round   int          `json:"round"`   // combination rounds needed, 0 for owned elements
+	Recipes []RecipeStep `json:"recipes"` // recipes that craft it in its round
+	Unlocks []string     `json:"unlocks"` // later discoveries crafted with it
+}
+
+type Reachability struct {
+	Elements  []Discovery `json:"elements"` // ordered by round then name
+	Rounds    int         `json:"rounds"`
+	Reachable int         `json:"reachable"` // elements owned or discovered
+}
+
+// Reachable computes every element craftable from owned under the tier rule
+// BFS uses. each round combines everything known after the previous round, so
+// an element's round is the fewest rounds of combining needed to discover it
+func Reachable(owned map[string]bool, uses UsesIndex, tiers map[string]int) Reachability {
+	rounds := make(map[string]int)
+	recipes := make(map[string][]RecipeStep)
+	unlocks := make(map[string][]string)
+
+	var frontier []string
+	for element := range owned {
+		rounds[element] = 0
+		frontier = append(frontier, element)
+	}
+	sort.Strings(frontier)
+
+	round := 0
+	for len(frontier) > 0 {
+		round++
+		var next []string
+		for _, current := range frontier {
+			for _, recipe := range uses[current] {
+				if r, known := rounds[recipe.Result]; known && r < round {
+					continue
+				}
+				// both ingredients must be known before this round
+				r1, ok1 := rounds[recipe.Ingredient1]
+				r2, ok2 := rounds[recipe.Ingredient2]
+				if !ok1 || !ok2 || r1 >= round || r2 >= round {
+					continue
+				}
+				if !tierAllows([2]string{recipe.Ingredient1, recipe.Ingredient2}, recipe.Result, tiers) {
+					continue
+				}
+
+				if _, known := rounds[recipe.Result]; !known {
+					rounds[recipe.Result] = round
+					next = append(next, recipe.Result)
+				}
+				if !containsRecipe(recipes[recipe.Result], recipe) {
+					recipes[recipe.Result] = append(recipes[recipe.Result], recipe)
+				}
+			}
+		}
+		sort.Strings(next)
+		frontier = next
+	}
+
+	for element, elementRecipes := range recipes {
+		for _, recipe := range elementRecipes {
+			unlocks[recipe.Ingredient1] = appendUnique(unlocks[recipe.Ingredient1], element)
+			unlocks[recipe.Ingredient2] = appendUnique(unlocks[recipe.Ingredient2], element)
+		}
+	}
+
+	result := Reachability{Reachable: len(rounds)}
+	for element, r := range rounds {
+		discovery := Discovery{Element: element, Round: r, Recipes: recipes[element], Unlocks: unlocks[element]}
+		if discovery.Recipes == nil {
+			discovery.Recipes = []RecipeStep{}
+		}
+		if discovery.Unlocks == nil {
+			discovery.Unlocks = []string{}
+		}
+		sort.Strings(discovery.Unlocks)
+		result.Elements = append(result.Elements, discovery)
+		if r > result.Rounds {
+			result.Rounds = r
+		}
+	}
+	sort.Slice(result.Elements, func(i, j int) bool {
+		if result.Elements[i].Round != result.Elements[j].Round {
+			return result.Elements[i].Round < result.Elements[j].Round
+		}
+		return result.Elements[i].Element < result.Elements[j].Element
+	})
+	return result
+}
+
+func containsRecipe(recipes []RecipeStep, recipe RecipeStep) bool {
+	for _, existing := range recipes {
+		if existing.Result == recipe.Result && pairKey(existing.Ingredient1, existing.Ingredient2) == pairKey(recipe.Ingredient1, recipe.Ingredient2) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(slice []string, item string) []string {
+	if contains(slice, item) {
+		return slice
+	}
+	return append(slice, item)
+}
+
+// FindReachable is Reachable over the dataset, the base elements are always owned
+func (d *Dataset) FindReachable(inventory []string) (Reachability, error) {
+	opts := SearchOptions{Inventory: inventory}
+	if err := opts.validate(d.Tiers); err != nil {
+		return Reachability{}, err
+	}
+	return Reachable(opts.leaves(), d.Uses, d.Tiers), nil
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+)
+
+// SearchRequest is one query handed to a Searcher
+type SearchRequest struct {
+	Target       string
+	FindShortest bool // return only the smallest tree
+	MaxRecipes   int  // max number of trees otherwise
+	Options      SearchOptions
+}
+
+// Capabilities tells clients which parts of a request a searcher honours
+type Capabilities struct {
+	MultipleTrees bool `json:"multipleTrees"` // honours shortest and max, otherwise always one tree
+	Optimal       bool `json:"optimal"`       // proves its result minimal, SearchResult.Optimal tells whether it did
+	TierPolicies  bool `json:"tierPolicies"`  // honours tier and reports discarded
+	Seed          bool `json:"seed"`          // honours seed
+	Workers       bool `json:"workers"`       // honours workers
+	Costs         bool `json:"costs"`         // honours costs, recipeCosts and tierCost
+}
+
+// Searcher is one search strategy selectable with the algo parameter.
+// every searcher honours inventory, forbidden and required and emits events
+type Searcher interface {
+	Name() string
+	Description() string
+	Capabilities() Capabilities
+	Search(ctx context.Context, data *Dataset, req SearchRequest, onEvent EventFunc) (SearchResult, error)
+}
+
+var (
+	searchers     = make(map[string]Searcher)
+	searcherNames []string // registration order
+)
+
+// RegisterSearcher makes s selectable by its name, names must be unique
+func RegisterSearcher(s Searcher) {
+	if _, exists := searchers[s.Name()]; exists {
+		panic(fmt.Sprintf("searcher %s registered twice", s.Name()))
+	}
+	searchers[s.Name()] = s
+	searcherNames = append(searcherNames, s.Name())
+}
+
+func LookupSearcher(name string) (Searcher, error) {
+	s, exists := searchers[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown algorithm %s, expected one of %v", name, searcherNames)
+	}
+	return s, nil
+}
+
+// SearcherInfo is how a searcher is listed to clients
+type SearcherInfo struct {
+	Name         string       `json:"name"`
+	Description  string       `json:"description"`
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// Searchers lists every registered searcher in registration order
+func Searchers() []SearcherInfo {
+	infos := make([]SearcherInfo, 0, len(searcherNames))
+	for _, name := range searcherNames {
+		s := searchers[name]
+		infos = append(infos, SearcherInfo{Name: s.Name(), Description: s.Description(), Capabilities: s.Capabilities()})
+	}
+	return infos
+}
+
+// Traversal is the signature BFS and DFS share
+type Traversal func(ctx context.Context, target string, index PairIndex, tiers map[string]int, maxRecipes int, opts SearchOptions, onEvent EventFunc) ([]RecipePath, SearchStats, error)
+
+// traversalSearcher runs a tier pruned traversal and enumerates trees from the
+// recipe variants it found, see Dataset.Search
+type traversalSearcher struct {
+	name        string
+	description string
+	traverse    Traversal
+	parallel    bool
+}
+
+func (s traversalSearcher) Name() string        { return s.name }
+func (s traversalSearcher) Description() string { return s.description }
+
+func (s traversalSearcher) Capabilities() Capabilities {
+	return Capabilities{MultipleTrees: true, TierPolicies: true, Seed: true, Workers: s.parallel}
+}
+
+func (s traversalSearcher) Search(ctx context.Context, data *Dataset, req SearchRequest, onEvent EventFunc) (SearchResult, error) {
+	fmt.Printf("Finding recipe using %s...\n", s.name)
+	return data.Search(ctx, s.traverse, req, onEvent)
+}
+
+type optimalSearcher struct{}
+
+func (optimalSearcher) Name() string { return "OPTIMAL" }
+
+func (optimalSearcher) Description() string {
+	return "Exact minimum tree over every recipe, ignoring tiers. shortest and max are ignored"
+}
+
+func (optimalSearcher) Capabilities() Capabilities {
+	return Capabilities{Optimal: true}
+}
+
+func (optimalSearcher) Search(ctx context.Context, data *Dataset, req SearchRequest, onEvent EventFunc) (SearchResult, error) {
+	return data.SearchOptimal(ctx, req.Target, req.Options, onEvent)
+}
+
+type cheapestSearcher struct{}
+
+func (cheapestSearcher) Name() string { return "CHEAPEST" }
+
+func (cheapestSearcher) Description() string {
+	return "Minimum total cost tree over every recipe, ignoring tiers. shortest and max are ignored"
+}
+
+func (cheapestSearcher) Capabilities() Capabilities {
+	return Capabilities{Optimal: true, Costs: true}
+}
+
+func (cheapestSearcher) Search(ctx context.Context, data *Dataset, req SearchRequest, onEvent EventFunc) (SearchResult, error) {
+	return data.SearchCheapest(ctx, req.Target, req.Options, onEvent)
+}
+
+type paretoSearcher struct{}
+
+func (paretoSearcher) Name() string { return "PARETO" }
+
+func (paretoSearcher) Description() string {
+	return "Every tree trading depth against node count, shallowest first, over every recipe ignoring tiers. shortest and max are ignored"
+}
+
+func (paretoSearcher) Capabilities() Capabilities {
+	return Capabilities{Optimal: true}
+}
+
+func (paretoSearcher) Search(ctx context.Context, data *Dataset, req SearchRequest, onEvent EventFunc) (SearchResult, error) {
+	return data.SearchPareto(ctx, req.Target, req.Options, onEvent)
+}
+
+type dagSearcher struct{}
+
+func (dagSearcher) Name() string { return "DAG" }
+
+func (dagSearcher) Description() string {
+	return "Crafting DAG with the fewest unique combinations, every element crafted once and reused, over every recipe ignoring tiers. shortest and max are ignored"
+}
+
+func (dagSearcher) Capabilities() Capabilities {
+	return Capabilities{Optimal: true}
+}
+
+func (dagSearcher) Search(ctx context.Context, data *Dataset, req SearchRequest, onEvent EventFunc) (SearchResult, error) {
+	return data.SearchDAG(ctx, req.Target, req.Options, onEvent)
+}
+
+func init() {
+	RegisterSearcher(traversalSearcher{"BFS", "Breadth first exploration, finds small trees first", BFS, false})
+	RegisterSearcher(traversalSearcher{"DFS", "Depth first exploration, reaches deep elements with fewer visits", DFS, false})
+	RegisterSearcher(traversalSearcher{"PARALLEL_BFS", "BFS with every frontier level and the tree building expanded by a worker pool, same results as BFS", ParallelBFS, true})
+	RegisterSearcher(optimalSearcher{})
+	RegisterSearcher(cheapestSearcher{})
+	RegisterSearcher(paretoSearcher{})
+	RegisterSearcher(dagSearcher{})
+}
@@ -0,0 +1,119 @@
+package utils
+
+import "fmt"
+
+// TierPolicy decides which recipes BFS and DFS accept while exploring
+type TierPolicy string
+
+const (
+	TierStrict    TierPolicy = "strict"    // result tier above both ingredient tiers (default)
+	TierNonStrict TierPolicy = "nonstrict" // result tier at least both ingredient tiers
+	TierNone      TierPolicy = "none"      // any recipe
+)
+
+var TierPolicies = []TierPolicy{TierStrict, TierNonStrict, TierNone}
+
+func ParseTierPolicy(value string) (TierPolicy, error) {
+	if value == "" {
+		return TierStrict, nil
+	}
+	for _, policy := range TierPolicies {
+		if string(policy) == value {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown tier policy %s, expected one of %v", value, TierPolicies)
+}
+
+// allows reports whether the policy accepts ing1 + ing2 = result. policies that
+// let tiers stay equal can loop, so they never accept a recipe that crafts one
+// of its own ingredients or an element the search starts with
+func (policy TierPolicy) allows(ing1, ing2, result string, tiers map[string]int, leaves map[string]bool) bool {
+	switch policy {
+	case TierNonStrict:
+		return tiers[result] >= tiers[ing1] && tiers[result] >= tiers[ing2] && !loops(ing1, ing2, result, leaves)
+	case TierNone:
+		return !loops(ing1, ing2, result, leaves)
+	default:
+		return tiers[result] > tiers[ing1] && tiers[result] > tiers[ing2]
+	}
+}
+
+func loops(ing1, ing2, result string, leaves map[string]bool) bool {
+	return result == ing1 || result == ing2 || leaves[result]
+}
+
+// tierAllows is the strict rule, what BFS and DFS apply by default
+func tierAllows(recipe [2]string, result string, tiers map[string]int) bool {
+	return TierStrict.allows(recipe[0], recipe[1], result, tiers, nil)
+}
+
+// acyclicVariants drops the recipe variants that could make a tree loop back
+// on itself. strict pruning only records recipes going up in tier, which can
+// never form a cycle. under nonstrict a cycle has to stay within one tier, and
+// under none it can go anywhere, so those recipes are only kept when both
+// ingredients were discovered before the result. the recipe that first made an
+// element craftable always passes, so every craftable element keeps a tree
+func acyclicVariants(recipeVariants map[string][]RecipeStep, discovered map[string]int, tiers map[string]int, policy TierPolicy) map[string][]RecipeStep {
+	if policy == TierStrict {
+		return recipeVariants
+	}
+
+	filtered := make(map[string][]RecipeStep, len(recipeVariants))
+	for element, variants := range recipeVariants {
+		for _, variant := range variants {
+			risesInTier := tiers[element] > tiers[variant.Ingredient1] && tiers[element] > tiers[variant.Ingredient2]
+			order := discovered[element]
+			discoveredFirst := discovered[variant.Ingredient1] < order && discovered[variant.Ingredient2] < order
+			if (policy == TierNonStrict && risesInTier) || discoveredFirst {
+				filtered[element] = append(filtered[element], variant)
+			}
+		}
+	}
+	return filtered
+}
+
+// PruneStats counts the distinct candidate recipes a search looked at that
+// each tier policy would discard
+type PruneStats map[TierPolicy]int
+
+type pruneCounter struct {
+	stats PruneStats
+	seen  map[RecipeStep]bool
+}
+
+func newPruneCounter() *pruneCounter {
+	stats := make(PruneStats, len(TierPolicies))
+	for _, policy := range TierPolicies {
+		stats[policy] = 0
+	}
+	return &pruneCounter{stats: stats, seen: make(map[RecipeStep]bool)}
+}
+
+// rejectingPolicies returns a mask with bit i set when TierPolicies[i]
+// discards ing1 + ing2 = result
+func rejectingPolicies(ing1, ing2, result string, tiers map[string]int, leaves map[string]bool) uint {
+	var rejected uint
+	for i, policy := range TierPolicies {
+		if !policy.allows(ing1, ing2, result, tiers, leaves) {
+			rejected |= 1 << i
+		}
+	}
+	return rejected
+}
+
+// count adds a recipe the first time it is seen, rejected as returned by rejectingPolicies
+func (c *pruneCounter) count(ing1, ing2, result string, rejected uint) {
+	pair := pairKey(ing1, ing2)
+	key := RecipeStep{Ingredient1: pair[0], Ingredient2: pair[1], Result: result}
+	if c.seen[key] {
+		return
+	}
+	c.seen[key] = true
+
+	for i, policy := range TierPolicies {
+		if rejected&(1<<i) != 0 {
+			c.stats[policy]++
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ingredient -> recipes it takes part in, the reverse of graph
+type UsesIndex map[string][]RecipeStep
+
+func (idx UsesIndex) add(recipe RecipeStep) {
+	idx[recipe.Ingredient1] = append(idx[recipe.Ingredient1], recipe)
+	if recipe.Ingredient2 != recipe.Ingredient1 {
+		idx[recipe.Ingredient2] = append(idx[recipe.Ingredient2], recipe)
+	}
+}
+
+// BuildUsesIndex indexes a result -> ingredients graph by ingredient
+func BuildUsesIndex(graph map[string][][2]string) UsesIndex {
+	idx := make(UsesIndex)
+	for result, recipes := range graph {
+		for _, recipe := range recipes {
+			idx.add(RecipeStep{Ingredient1: recipe[0], Ingredient2: recipe[1], Result: result})
+		}
+	}
+	return idx
+}
+
+// UseLevel is every element first reached at Depth combinations away
+type UseLevel struct {
+	Depth    int      `json:"depth"`
+	Elements []string `json:"elements"`
+}
+
+type ElementUses struct {
+	Element    string       `json:"element"`
+	Recipes    []RecipeStep `json:"recipes"`              // recipes element is an ingredient of
+	Downstream []UseLevel   `json:"downstream,omitempty"` // closure of results, by depth
+}
+
+// Uses lists the recipes element is an ingredient of. with depth > 0 it also
+// follows the results downstream: depth 1 holds the direct results, depth 2
+// what those results are used for, and so on. depth < 0 follows to the end
+func (idx UsesIndex) Uses(element string, depth int) ElementUses {
+	uses := ElementUses{Element: element, Recipes: idx[element]}
+	if uses.Recipes == nil {
+		uses.Recipes = []RecipeStep{}
+	}
+
+	seen := map[string]bool{element: true}
+	frontier := []string{element}
+	for level := 1; len(frontier) > 0 && (depth < 0 || level <= depth); level++ {
+		var next []string
+		for _, current := range frontier {
+			for _, recipe := range idx[current] {
+				if !seen[recipe.Result] {
+					seen[recipe.Result] = true
+					next = append(next, recipe.Result)
+				}
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+
+		sort.Strings(next)
+		uses.Downstream = append(uses.Downstream, UseLevel{Depth: level, Elements: next})
+		frontier = next
+	}
+	return uses
+}
+
+// FindUses is Uses over the dataset
+func (d *Dataset) FindUses(element string, depth int) (ElementUses, error) {
+	if _, exists := d.Tiers[element]; !exists {
+		return ElementUses{}, fmt.Errorf("unknown element %s", element)
+	}
+	return d.Uses.Uses(element, depth), nil
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// ValidationCheck is one kind of problem and every place it was found
+type ValidationCheck struct {
+	Name     string   `json:"name"`
+	Severity string   `json:"severity"`
+	Issues   []string `json:"issues"`
+}
+
+type ValidationReport struct {
+	Checks []ValidationCheck `json:"checks"`
+}
+
+func (r *ValidationReport) add(name, severity string, issues []string) {
+	sort.Strings(issues)
+	r.Checks = append(r.Checks, ValidationCheck{Name: name, Severity: severity, Issues: issues})
+}
+
+func (r ValidationReport) HasErrors() bool {
+	for _, check := range r.Checks {
+		if check.Severity == SeverityError && len(check.Issues) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes every check with at most limit issues each, limit <= 0 prints all
+func (r ValidationReport) Print(w io.Writer, limit int) {
+	for _, check := range r.Checks {
+		if len(check.Issues) == 0 {
+			fmt.Fprintf(w, "[ok] %s\n", check.Name)
+			continue
+		}
+
+		fmt.Fprintf(w, "[%s] %s: %d\n", check.Severity, check.Name, len(check.Issues))
+		for i, issue := range check.Issues {
+			if limit > 0 && i >= limit {
+				fmt.Fprintf(w, "  ... %d more\n", len(check.Issues)-limit)
+				break
+			}
+			fmt.Fprintf(w, "  - %s\n", issue)
+		}
+	}
+}
+
+func readElements(filename string) ([]Element, error) {
+	file, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading elements file: %w", err)
+	}
+
+	var elements []Element
+	if err := json.Unmarshal(file, &elements); err != nil {
+		return nil, fmt.Errorf("error unmarshaling elements: %w", err)
+	}
+	return elements, nil
+}
+
+// ValidateDataset checks that recipes.json and elements.json agree and that the
+// recipes make sense. broken files and inconsistencies between the two files are
+// errors. recipes the searches simply prune or never reach are warnings, the
+// scraped Little Alchemy 2 data has plenty of those
+func ValidateDataset(recipesFile, elementsFile string) ValidationReport {
+	recipes, elements, report := readDataset(recipesFile, elementsFile)
+	if report.HasErrors() {
+		return report
+	}
+	return validateRecipes(recipes, elements)
+}
+
+// readDataset reads both files, a file that cannot be read is an error in the report
+func readDataset(recipesFile, elementsFile string) ([]Recipe, []Element, ValidationReport) {
+	var report ValidationReport
+
+	recipes, err := readRecipes(recipesFile)
+	if err != nil {
+		report.add("recipes file", SeverityError, []string{err.Error()})
+	}
+	elements, err := readElements(elementsFile)
+	if err != nil {
+		report.add("elements file", SeverityError, []string{err.Error()})
+	}
+	return recipes, elements, report
+}
+
+// validateRecipes is ValidateDataset over files already read
+func validateRecipes(recipes []Recipe, elements []Element) ValidationReport {
+	var report ValidationReport
+	graph, tiers := buildGraph(recipes)
+
+	// cross check the two files
+	elementTiers := make(map[string]int)
+	for _, element := range elements {
+		elementTiers[element.Name] = element.Tier
+	}
+
+	inRecipes := make(map[string]bool)
+	var malformed []string
+	for i, r := range recipes {
+		if len(r.Recipe) != 2 || r.Result == "" || r.Recipe[0] == "" || r.Recipe[1] == "" {
+			malformed = append(malformed, fmt.Sprintf("recipe #%d for %q has ingredients %q", i, r.Result, r.Recipe))
+			continue
+		}
+		inRecipes[r.Result] = true
+		inRecipes[r.Recipe[0]] = true
+		inRecipes[r.Recipe[1]] = true
+	}
+	report.add("malformed recipes", SeverityError, malformed)
+
+	var missingElements, missingRecipes, tierMismatch []string
+	for element := range inRecipes {
+		if _, exists := elementTiers[element]; !exists {
+			missingElements = append(missingElements, element)
+		}
+	}
+	for element, tier := range elementTiers {
+		if !inRecipes[element] {
+			missingRecipes = append(missingRecipes, element)
+		} else if recipeTier, exists := tiers[element]; exists && recipeTier != tier {
+			tierMismatch = append(tierMismatch, fmt.Sprintf("%s: tier %d in recipes, %d in elements", element, recipeTier, tier))
+		}
+	}
+	report.add("elements in recipes missing from elements", SeverityError, missingElements)
+	report.add("elements missing from recipes", SeverityError, missingRecipes)
+	report.add("tiers differing between files", SeverityError, tierMismatch)
+
+	// the recipes themselves
+	var duplicates, selfReferential, tierViolations []string
+	seen := make(map[RecipeStep]bool)
+	for result, pairs := range graph {
+		for _, pair := range pairs {
+			recipe := fmt.Sprintf("%s + %s = %s", pair[0], pair[1], result)
+
+			key := pairKey(pair[0], pair[1])
+			step := RecipeStep{Ingredient1: key[0], Ingredient2: key[1], Result: result}
+			if seen[step] {
+				duplicates = append(duplicates, recipe)
+			}
+			seen[step] = true
+
+			if pair[0] == result || pair[1] == result {
+				selfReferential = append(selfReferential, recipe)
+			}
+			if !tierAllows(pair, result, tiers) {
+				tierViolations = append(tierViolations, fmt.Sprintf("%s (tiers %d + %d = %d)", recipe, tiers[pair[0]], tiers[pair[1]], tiers[result]))
+			}
+		}
+	}
+	report.add("duplicate recipes", SeverityError, duplicates)
+	report.add("self-referential recipes", SeverityWarning, selfReferential)
+	report.add("recipes with ingredient tiers not below the result tier", SeverityWarning, tierViolations)
+
+	// what the searches can actually reach
+	reachable := Reachable(SearchOptions{}.leaves(), BuildUsesIndex(graph), tiers)
+	reached := make(map[string]bool)
+	for _, discovery := range reachable.Elements {
+		reached[discovery.Element] = true
+	}
+	var unreachable []string
+	for element := range elementTiers {
+		if !reached[element] {
+			unreachable = append(unreachable, element)
+		}
+	}
+	report.add("elements unreachable from the base elements under tier pruning", SeverityWarning, unreachable)
+
+	return report
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RouteCheck is the verdict on a submitted crafting route. an invalid route
+// names the first step that cannot be crafted at that point
+type RouteCheck struct {
+	Valid  bool         `json:"valid"`
+	Target string       `json:"target"`
+	Steps  []RecipeStep `json:"steps"`           // the route in crafting order, a tree is flattened
+	Step   int          `json:"step,omitempty"`  // failing step counting from 1, 0 when the route itself is wrong
+	Path   []string     `json:"path,omitempty"`  // trees only, elements from the root down to the failing node
+	Error  string       `json:"error,omitempty"` // why the route is invalid
+}
+
+func (check RouteCheck) fail(step int, format string, args ...interface{}) RouteCheck {
+	check.Valid = false
+	check.Step = step
+	check.Error = fmt.Sprintf(format, args...)
+	return check
+}
+
+// VerifySteps replays steps in order from the base and inventory elements:
+// every step must be a recipe of graph whose ingredients are already
+// available, and target must be available at the end. target defaults to
+// the result of the last step
+func VerifySteps(graph map[string][][2]string, target string, steps []RecipeStep, opts SearchOptions) RouteCheck {
+	if target == "" && len(steps) > 0 {
+		target = steps[len(steps)-1].Result
+	}
+	check := RouteCheck{Valid: true, Target: target, Steps: steps}
+	if target == "" {
+		return check.fail(0, "no target and no steps")
+	}
+
+	available := opts.leaves()
+	for i, step := range steps {
+		if step.Ingredient1 == "" || step.Ingredient2 == "" || step.Result == "" {
+			return check.fail(i+1, "step %d needs two ingredients and a result", i+1)
+		}
+		if !hasRecipe(graph, step) {
+			return check.fail(i+1, "step %d: %s + %s does not make %s", i+1, step.Ingredient1, step.Ingredient2, step.Result)
+		}
+		for _, ingredient := range []string{step.Ingredient1, step.Ingredient2} {
+			if !available[ingredient] {
+				return check.fail(i+1, "step %d: %s is not available yet", i+1, ingredient)
+			}
+		}
+		available[step.Result] = true
+	}
+
+	if !available[target] {
+		return check.fail(0, "the steps never craft %s", target)
+	}
+	return check
+}
+
+// VerifyTree checks a tree in JSONRecipeNode format with at most one recipe
+// per node. it is flattened into steps, children first, and replayed like
+// VerifySteps, so an element without a recipe must be a base or inventory one
+func VerifyTree(graph map[string][][2]string, root *JSONRecipeNode, opts SearchOptions) RouteCheck {
+	if root == nil || root.Name == "" {
+		return RouteCheck{Error: "tree has no root element"}
+	}
+
+	var steps []RecipeStep
+	var paths [][]string
+	seen := make(map[RecipeStep]bool)
+	var malformed *RouteCheck
+
+	var visit func(node *JSONRecipeNode, path []string)
+	visit = func(node *JSONRecipeNode, path []string) {
+		if malformed != nil {
+			return
+		}
+		path = append(path[:len(path):len(path)], node.Name)
+		if len(node.Recipes) == 0 {
+			return
+		}
+		if len(node.Recipes) > 1 {
+			malformed = &RouteCheck{Target: root.Name, Path: path, Error: fmt.Sprintf("%s has %d recipes, submit one tree at a time", node.Name, len(node.Recipes))}
+			return
+		}
+
+		recipe := node.Recipes[0]
+		if recipe[0] == nil || recipe[1] == nil || recipe[0].Name == "" || recipe[1].Name == "" {
+			malformed = &RouteCheck{Target: root.Name, Path: path, Error: fmt.Sprintf("the recipe of %s needs two ingredients", node.Name)}
+			return
+		}
+		visit(recipe[0], path)
+		visit(recipe[1], path)
+
+		step := RecipeStep{Ingredient1: recipe[0].Name, Ingredient2: recipe[1].Name, Result: node.Name}
+		if !seen[recipeCostKey(step)] {
+			seen[recipeCostKey(step)] = true
+			steps = append(steps, step)
+			paths = append(paths, path)
+		}
+	}
+	visit(root, nil)
+	if malformed != nil {
+		return *malformed
+	}
+
+	check := VerifySteps(graph, root.Name, steps, opts)
+	if check.Step > 0 {
+		check.Path = paths[check.Step-1]
+		check.Error += " (at " + strings.Join(check.Path, " > ") + ")"
+	}
+	return check
+}
+
+// VerifyRoute checks a submitted tree, or steps when tree is nil, against the
+// dataset. target is optional for steps and must match the root of a tree
+func (d *Dataset) VerifyRoute(target string, steps []RecipeStep, tree *JSONRecipeNode, inventory []string) (RouteCheck, error) {
+	opts := SearchOptions{Inventory: inventory}
+	if err := opts.validate(d.Tiers); err != nil {
+		return RouteCheck{}, err
+	}
+	if target != "" {
+		if _, exists := d.Tiers[target]; !exists {
+			return RouteCheck{}, fmt.Errorf("unknown element %s", target)
+		}
+	}
+
+	if tree == nil {
+		return VerifySteps(d.Graph, target, steps, opts), nil
+	}
+	if target != "" && target != tree.Name {
+		return RouteCheck{Target: target, Path: []string{tree.Name}, Error: fmt.Sprintf("tree crafts %s, not %s", tree.Name, target)}, nil
+	}
+	return VerifyTree(d.Graph, tree, opts), nil
+}
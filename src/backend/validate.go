@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"backend/utils"
+)
+
+// go run . validate [-recipes recipes.json] [-elements elements.json]
+// prints every issue and exits with 1 when the dataset has errors
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	recipesFile := flags.String("recipes", "recipes.json", "recipes file to validate")
+	elementsFile := flags.String("elements", "elements.json", "elements file to validate")
+	flags.Parse(args)
+
+	report := utils.ValidateDataset(*recipesFile, *elementsFile)
+	report.Print(os.Stdout, 0)
+
+	if report.HasErrors() {
+		fmt.Println("dataset has errors")
+		return 1
+	}
+	fmt.Println("dataset is valid")
+	return 0
+}
+
+// print what validating a dataset found on startup, only errors stop the server
+func reportOnStartup(report utils.ValidationReport) {
+	report.Print(os.Stdout, 3)
+
+	if report.HasErrors() {
+		fmt.Println("dataset has errors, run `go run . validate` for the full report")
+	}
+}